@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"auth/internal/models"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+const (
+	codeChallengeS256 = "S256"
+	// authorizationCodeLength is the number of random bytes in a code.
+	authorizationCodeLength = 32
+	// codeVerifier lengths allowed by RFC 7636 section 4.1.
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+)
+
+// authorizationCodeTTL is how long a code can be exchanged, RFC 6749 recommends at most 10 minutes.
+const authorizationCodeTTL = time.Minute
+
+// Authorize starts the authorization code flow for the user logged in with the
+// access token of the request. The code is sent to the registered redirect URI
+// and can only be exchanged together with the PKCE code verifier.
+func (a *AuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	client, err := a.client.GetByID(context.Background(), query.Get("client_id"))
+	if errors.Is(err, models.ErrNotFound) {
+		a.log.Error("unknown client", slog.Any("client", query.Get("client_id")))
+		a.writeOAuthError(w, errInvalidRequest, "unknown client", http.StatusBadRequest)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to get client", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	// errors are sent to the redirect URI only after it is known to belong to the client
+	redirectURI, ok := clientRedirectURI(client, query.Get("redirect_uri"))
+	if !ok {
+		a.log.Error("redirect uri is not registered", slog.Any("client", client.ID),
+			slog.Any("redirect_uri", query.Get("redirect_uri")))
+		a.writeOAuthError(w, errInvalidRequest, "invalid redirect_uri", http.StatusBadRequest)
+
+		return
+	}
+
+	state := query.Get("state")
+
+	if query.Get("response_type") != "code" {
+		redirectError(w, r, redirectURI, state, errResponseType, "")
+
+		return
+	}
+
+	challenge := query.Get("code_challenge")
+	if query.Get("code_challenge_method") != codeChallengeS256 || len(challenge) != sha256EncodedLength {
+		redirectError(w, r, redirectURI, state, errInvalidRequest, "PKCE with S256 is required")
+
+		return
+	}
+
+	scopes, ok := grantScopes(client, query.Get("scope"))
+	if !ok {
+		redirectError(w, r, redirectURI, state, errInvalidScope, "")
+
+		return
+	}
+
+	token, ok := accessToken(r)
+	if !ok {
+		redirectError(w, r, redirectURI, state, errLoginRequired, "")
+
+		return
+	}
+
+	user, err := a.jwt.ParseUser(context.Background(), token)
+	if err != nil {
+		a.log.Error("invalid access token", slog.Any("error", err))
+		redirectError(w, r, redirectURI, state, errLoginRequired, "")
+
+		return
+	}
+
+	// the user authenticated when the session of the access token was created
+	login, err := a.session.GetByID(context.Background(), user.SessionID.String())
+	if err != nil || login.Revoked {
+		a.log.Error("login session not found", slog.Any("GUID", user.ID), slog.Any("session", user.SessionID))
+		redirectError(w, r, redirectURI, state, errLoginRequired, "")
+
+		return
+	}
+
+	stored, err := a.user.GetByGUID(context.Background(), user.ID.String())
+	if err != nil {
+		a.log.Error("failed to get user", slog.Any("GUID", user.ID), slog.Any("error", err))
+		redirectError(w, r, redirectURI, state, errServerError, "")
+
+		return
+	}
+
+	scopes = userScopes(stored, scopes)
+
+	code, err := generateRefreshToken(authorizationCodeLength)
+	if err != nil {
+		a.log.Error("failed to generate authorization code", slog.Any("error", err))
+		redirectError(w, r, redirectURI, state, errServerError, "")
+
+		return
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(code)
+
+	err = a.code.Add(context.Background(), &models.AuthorizationCode{
+		Code:          hashCode(encoded),
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		SessionID:     uuid.New(),
+		RedirectURI:   query.Get("redirect_uri"),
+		Scopes:        scopes,
+		CodeChallenge: challenge,
+		Nonce:         query.Get("nonce"),
+		AuthTime:      login.CreatedAt,
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		a.log.Error("failed to add authorization code", slog.Any("error", err))
+		redirectError(w, r, redirectURI, state, errServerError, "")
+
+		return
+	}
+
+	redirect(w, r, redirectURI, url.Values{"code": {encoded}}, state)
+
+	a.log.Info("authorization code issued", slog.Any("client", client.ID), slog.Any("GUID", user.ID))
+}
+
+// authorizationCodeGrant exchanges a code issued by Authorize for a new session.
+// A replayed code revokes the session created by its first exchange.
+func (a *AuthHandler) authorizationCodeGrant(w http.ResponseWriter, r *http.Request, client *models.Client) {
+	encoded := r.PostFormValue("code")
+	if encoded == "" {
+		a.writeOAuthError(w, errInvalidRequest, "code is required", http.StatusBadRequest)
+
+		return
+	}
+
+	code, err := a.code.Consume(context.Background(), hashCode(encoded))
+	if errors.Is(err, models.ErrNotFound) {
+		a.log.Error("unknown authorization code", slog.Any("client", client.ID))
+		a.writeOAuthError(w, errInvalidGrant, "invalid authorization code", http.StatusBadRequest)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to consume authorization code", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	if code.Used {
+		a.securityEvent("authorization_code_reuse", slog.Any("client", client.ID), slog.Any("session", code.SessionID))
+		a.revokeSession(code.SessionID)
+		a.writeOAuthError(w, errInvalidGrant, "invalid authorization code", http.StatusBadRequest)
+
+		return
+	}
+
+	// RFC 6749 section 4.1.3, the redirect URI must be repeated only if it was sent to Authorize
+	redirectMismatch := code.RedirectURI != "" && code.RedirectURI != r.PostFormValue("redirect_uri")
+
+	if code.ClientID != client.ID || redirectMismatch || time.Now().After(code.ExpiresAt) {
+		a.log.Error("authorization code does not match request", slog.Any("client", client.ID))
+		a.writeOAuthError(w, errInvalidGrant, "invalid authorization code", http.StatusBadRequest)
+
+		return
+	}
+
+	if !verifyCodeChallenge(r.PostFormValue("code_verifier"), code.CodeChallenge) {
+		a.log.Error("invalid code verifier", slog.Any("client", client.ID))
+		a.writeOAuthError(w, errInvalidGrant, "invalid code verifier", http.StatusBadRequest)
+
+		return
+	}
+
+	user, refreshToken, err := a.startClientSession(r, code.SessionID, code.UserID, client, code.Scopes)
+	if err != nil {
+		a.log.Error("failed to start session", slog.Any("error", err.Error()))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	var idToken string
+	if slices.Contains(code.Scopes, scopeOpenID) {
+		idToken, err = a.jwt.IssueIDToken(user, code.Nonce, code.AuthTime)
+		if err != nil {
+			a.log.Error("failed to generate id token", slog.Any("error", err.Error()))
+			a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	a.writeTokens(w, user, refreshToken, idToken)
+
+	a.log.Info("give tokens to client", slog.Any("client", client.ID), slog.Any("GUID", user.ID))
+}
+
+// startClientSession creates the session of the user on behalf of the client
+// and returns the user to issue the access token for with its refresh token.
+func (a *AuthHandler) startClientSession(
+	r *http.Request, sessionID, userID uuid.UUID, client *models.Client, scopes []string,
+) (*models.User, string, error) {
+	IPAddress := realIP(r)
+
+	session := &models.Session{
+		ID:        sessionID,
+		UserID:    userID,
+		Ip:        IPAddress,
+		UserAgent: r.UserAgent(),
+		ClientID:  client.ID,
+		Scopes:    scopes,
+	}
+
+	refreshToken, err := a.newRefreshToken(session)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user := &models.User{
+		ID:       userID,
+		Ip:       IPAddress,
+		ClientID: client.ID,
+		Scopes:   scopes,
+	}
+
+	pairTokens(user, session)
+
+	if err = a.session.Add(context.Background(), session); err != nil {
+		return nil, "", err
+	}
+
+	return user, refreshToken, nil
+}
+
+// clientRedirectURI checks the requested redirect URI against the registered ones
+// by exact match. It may be omitted if the client has a single registered URI.
+func clientRedirectURI(client *models.Client, requested string) (string, bool) {
+	if requested == "" {
+		if len(client.RedirectURIs) != 1 {
+			return "", false
+		}
+
+		return client.RedirectURIs[0], true
+	}
+
+	return requested, slices.Contains(client.RedirectURIs, requested)
+}
+
+// sha256EncodedLength is the length of a base64url encoded SHA-256 hash.
+var sha256EncodedLength = base64.RawURLEncoding.EncodedLen(sha256.Size)
+
+// verifyCodeChallenge checks the PKCE code verifier against the S256 challenge.
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(verifier))
+
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(hash[:])), []byte(challenge)) == 1
+}
+
+// hashCode returns the value stored for an authorization code, so a leaked
+// database does not expose codes that can still be exchanged.
+func hashCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI, state, code, description string) {
+	params := url.Values{"error": {code}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+
+	redirect(w, r, redirectURI, params, state)
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values, state string) {
+	target, _ := url.Parse(redirectURI)
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+
+	if state != "" {
+		query.Set("state", state)
+	}
+
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
@@ -1,7 +1,25 @@
 package auth
 
+import (
+	"errors"
+	"time"
+)
+
 const (
 	AccessToken  = "token"
 	RefreshToken = "refresh_token"
 	userEmail    = "user@example.com"
 )
+
+// maxRefreshTokenLength is the longest input bcrypt can hash.
+const maxRefreshTokenLength = 72
+
+var (
+	ErrInvalidTokenLength    = errors.New("refresh token length must be between 1 and 72 bytes")
+	ErrMalformedRefreshToken = errors.New("malformed refresh token")
+	ErrRefreshTokenReused    = errors.New("refresh token was already used")
+)
+
+// jwksMaxAge is how long resource servers may cache the key set,
+// new keys should be added to the ring at least this long before activation.
+const jwksMaxAge = 15 * time.Minute
@@ -0,0 +1,338 @@
+package auth
+
+import (
+	"auth/internal/models"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// deviceCodeTTL is how long the user has to approve the device.
+	deviceCodeTTL = 10 * time.Minute
+	// devicePollInterval is the minimal interval between polls, it grows by
+	// devicePollInterval each time the device polls too fast.
+	devicePollInterval = 5 * time.Second
+	// userCodeAlphabet has no vowels and no characters that are easy to confuse,
+	// RFC 8628 section 6.1.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+type DeviceAuthorizationResp struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type DeviceResp struct {
+	UserCode string   `json:"user_code"`
+	ClientID string   `json:"client_id"`
+	Client   string   `json:"client"`
+	Scopes   []string `json:"scopes"`
+}
+
+// DeviceAuthorization starts the device flow for devices that cannot open a
+// browser. The user approves the returned user code on the verification page
+// while the device polls the token endpoint with the device code.
+func (a *AuthHandler) DeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	client, err := a.authenticateClient(r)
+	if errors.Is(err, ErrInvalidClient) {
+		a.log.Error("invalid client credentials")
+		a.writeOAuthError(w, errInvalidClient, "client authentication failed", http.StatusUnauthorized)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to authenticate client", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	scopes, ok := grantScopes(client, r.PostFormValue("scope"))
+	if !ok {
+		a.writeOAuthError(w, errInvalidScope, "requested scope is not allowed", http.StatusBadRequest)
+
+		return
+	}
+
+	deviceCode, err := generateRefreshToken(authorizationCodeLength)
+	if err != nil {
+		a.log.Error("failed to generate device code", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		a.log.Error("failed to generate user code", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(deviceCode)
+
+	err = a.device.Add(context.Background(), &models.DeviceCode{
+		Code:      hashCode(encoded),
+		UserCode:  userCode,
+		ClientID:  client.ID,
+		Scopes:    scopes,
+		Status:    models.DevicePending,
+		Interval:  devicePollInterval,
+		ExpiresAt: time.Now().Add(deviceCodeTTL),
+	})
+	if err != nil {
+		a.log.Error("failed to add device code", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	verificationURI := strings.TrimSuffix(a.jwt.Issuer(), "/") + "/device"
+
+	a.writeOAuth(w, DeviceAuthorizationResp{
+		DeviceCode:              encoded,
+		UserCode:                formatUserCode(userCode),
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {formatUserCode(userCode)}}.Encode(),
+		ExpiresIn:               int64(deviceCodeTTL.Seconds()),
+		Interval:                int64(devicePollInterval.Seconds()),
+	})
+
+	a.log.Info("device authorization started", slog.Any("client", client.ID))
+}
+
+// Device shows the logged-in user which client asks for access with the user code.
+func (a *AuthHandler) Device(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	device, client, ok := a.pendingDevice(w, r.URL.Query().Get("user_code"))
+	if !ok {
+		return
+	}
+
+	a.writeSuccesful(w, DeviceResp{
+		UserCode: formatUserCode(device.UserCode),
+		ClientID: client.ID,
+		Client:   client.Name,
+		Scopes:   device.Scopes,
+	})
+
+	a.log.Info("device code shown to user", slog.Any("GUID", user.ID), slog.Any("client", client.ID))
+}
+
+// ApproveDevice records whether the logged-in user approves or denies the device,
+// the decision is picked up by the next poll of the device.
+func (a *AuthHandler) ApproveDevice(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	device, client, ok := a.pendingDevice(w, r.PostFormValue("user_code"))
+	if !ok {
+		return
+	}
+
+	var status models.DeviceCodeStatus
+
+	switch r.PostFormValue("action") {
+	case "approve":
+		status = models.DeviceApproved
+	case "deny":
+		status = models.DeviceDenied
+	default:
+		a.writeError(w, "action must be approve or deny", http.StatusBadRequest)
+
+		return
+	}
+
+	err := a.device.Approve(context.Background(), device.UserCode, user.ID, status)
+	if errors.Is(err, models.ErrNotFound) {
+		a.writeError(w, "invalid user code", http.StatusBadRequest)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to approve device", slog.Any("error", err))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+
+	a.writeSuccesful(w, DeviceResp{
+		UserCode: formatUserCode(device.UserCode),
+		ClientID: client.ID,
+		Client:   client.Name,
+		Scopes:   device.Scopes,
+	})
+
+	a.log.Info("device authorization decided", slog.Any("GUID", user.ID), slog.Any("client", client.ID),
+		slog.Any("status", status))
+}
+
+// pendingDevice finds the code the user entered, it writes the error response
+// if the code is unknown or no longer pending.
+func (a *AuthHandler) pendingDevice(w http.ResponseWriter, userCode string) (*models.DeviceCode, *models.Client, bool) {
+	device, err := a.device.GetByUserCode(context.Background(), normalizeUserCode(userCode))
+	if err == nil && (device.Status != models.DevicePending || time.Now().After(device.ExpiresAt)) {
+		err = models.ErrNotFound
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		a.writeError(w, "invalid user code", http.StatusBadRequest)
+
+		return nil, nil, false
+	}
+	if err != nil {
+		a.log.Error("failed to get device code", slog.Any("error", err))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return nil, nil, false
+	}
+
+	client, err := a.client.GetByID(context.Background(), device.ClientID)
+	if err != nil {
+		a.log.Error("failed to get client", slog.Any("error", err))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return nil, nil, false
+	}
+
+	return device, client, true
+}
+
+// deviceCodeGrant is polled by the device until the user decides. Polling faster
+// than the interval is answered with slow_down and increases the interval.
+func (a *AuthHandler) deviceCodeGrant(w http.ResponseWriter, r *http.Request, client *models.Client) {
+	encoded := r.PostFormValue("device_code")
+	if encoded == "" {
+		a.writeOAuthError(w, errInvalidRequest, "device_code is required", http.StatusBadRequest)
+
+		return
+	}
+
+	code := hashCode(encoded)
+
+	device, err := a.device.Poll(context.Background(), code)
+	if errors.Is(err, models.ErrNotFound) || err == nil && device.ClientID != client.ID {
+		a.log.Error("unknown device code", slog.Any("client", client.ID))
+		a.writeOAuthError(w, errInvalidGrant, "invalid device code", http.StatusBadRequest)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to poll device code", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	if time.Now().After(device.ExpiresAt) {
+		a.writeOAuthError(w, errExpiredToken, "", http.StatusBadRequest)
+
+		return
+	}
+
+	if !device.LastPolledAt.IsZero() && time.Since(device.LastPolledAt) < device.Interval {
+		if err = a.device.SlowDown(context.Background(), code, devicePollInterval); err != nil {
+			a.log.Error("failed to slow down device", slog.Any("error", err))
+		}
+
+		a.writeOAuthError(w, errSlowDown, "", http.StatusBadRequest)
+
+		return
+	}
+
+	switch device.Status {
+	case models.DevicePending:
+		a.writeOAuthError(w, errAuthorizationPending, "", http.StatusBadRequest)
+
+		return
+	case models.DeviceDenied:
+		a.writeOAuthError(w, errAccessDenied, "", http.StatusBadRequest)
+
+		return
+	case models.DeviceUsed:
+		a.writeOAuthError(w, errInvalidGrant, "invalid device code", http.StatusBadRequest)
+
+		return
+	}
+
+	// the user's scopes are known only once the user approved the device
+	stored, err := a.user.GetByGUID(context.Background(), device.UserID.String())
+	if err != nil {
+		a.log.Error("failed to get user", slog.Any("GUID", device.UserID), slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	err = a.device.Consume(context.Background(), code)
+	if errors.Is(err, models.ErrNotFound) {
+		a.writeOAuthError(w, errInvalidGrant, "invalid device code", http.StatusBadRequest)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to consume device code", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	user, refreshToken, err := a.startClientSession(r, uuid.New(), device.UserID, client,
+		userScopes(stored, device.Scopes))
+	if err != nil {
+		a.log.Error("failed to start session", slog.Any("error", err.Error()))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	a.writeTokens(w, user, refreshToken, "")
+
+	a.log.Info("give tokens to device", slog.Any("client", client.ID), slog.Any("GUID", user.ID))
+}
+
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	alphabetSize := big.NewInt(int64(len(userCodeAlphabet)))
+
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// formatUserCode splits the code in two halves for readability, e.g. WDJB-MJHT.
+func formatUserCode(code string) string {
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// normalizeUserCode accepts the code as typed by the user, in any case and with or without dashes.
+func normalizeUserCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
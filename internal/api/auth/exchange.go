@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"auth/internal/models"
+	"auth/pkg/jwt"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tokenTypeAccessToken is the only token type accepted and issued by token exchange.
+const tokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+// tokenExchangeGrant swaps the access token of a user for a token aimed at a
+// specific service, RFC 8693. The scope of the new token can only be narrowed,
+// it expires no later than the subject token and the exchanging client, or the
+// subject of an actor token issued to it, is recorded in the `act` claim on top
+// of any previous delegation.
+func (a *AuthHandler) tokenExchangeGrant(w http.ResponseWriter, r *http.Request, client *models.Client) {
+	if client.Public {
+		a.log.Error("public client requested token exchange", slog.Any("client", client.ID))
+		a.writeOAuthError(w, errUnauthorized, "public clients cannot exchange tokens", http.StatusBadRequest)
+
+		return
+	}
+
+	subjectToken := r.PostFormValue("subject_token")
+	if subjectToken == "" || r.PostFormValue("subject_token_type") != tokenTypeAccessToken {
+		a.writeOAuthError(w, errInvalidRequest, "access token is required as subject_token", http.StatusBadRequest)
+
+		return
+	}
+
+	if requested := r.PostFormValue("requested_token_type"); requested != "" && requested != tokenTypeAccessToken {
+		a.writeOAuthError(w, errInvalidRequest, "only access tokens can be requested", http.StatusBadRequest)
+
+		return
+	}
+
+	subject, err := a.jwt.ParseAccessToken(context.Background(), subjectToken)
+	if err != nil || subject.User == nil {
+		a.log.Error("invalid subject token", slog.Any("client", client.ID), slog.Any("error", err))
+		a.writeOAuthError(w, errInvalidGrant, "invalid subject token", http.StatusBadRequest)
+
+		return
+	}
+
+	actor := &models.Actor{
+		Subject: client.ID,
+		Actor:   subject.User.Actor,
+	}
+
+	if actorToken := r.PostFormValue("actor_token"); actorToken != "" {
+		if r.PostFormValue("actor_token_type") != tokenTypeAccessToken {
+			a.writeOAuthError(w, errInvalidRequest, "actor_token must be an access token", http.StatusBadRequest)
+
+			return
+		}
+
+		parsed, err := a.jwt.ParseAccessToken(context.Background(), actorToken)
+		if err != nil {
+			a.log.Error("invalid actor token", slog.Any("client", client.ID), slog.Any("error", err))
+			a.writeOAuthError(w, errInvalidGrant, "invalid actor token", http.StatusBadRequest)
+
+			return
+		}
+
+		// the client may only name itself, or a user acting through it, as the actor
+		if parsed.ClientID != client.ID {
+			a.log.Error("actor token issued to another client", slog.Any("client", client.ID),
+				slog.Any("actor_client", parsed.ClientID))
+			a.writeOAuthError(w, errInvalidRequest, "actor token was not issued to the client", http.StatusBadRequest)
+
+			return
+		}
+
+		actor.Subject = parsed.Subject
+	}
+
+	stored, err := a.user.GetByGUID(context.Background(), subject.User.ID.String())
+	if err != nil {
+		a.log.Error("failed to get user", slog.Any("GUID", subject.User.ID), slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	scopes, ok := exchangeScopes(subject.User, stored, client, r.PostFormValue("scope"))
+	if !ok {
+		a.log.Error("scope exceeds subject token", slog.Any("client", client.ID),
+			slog.Any("scope", r.PostFormValue("scope")))
+		a.writeOAuthError(w, errInvalidScope, "requested scope exceeds the subject token", http.StatusBadRequest)
+
+		return
+	}
+
+	audience := r.PostForm["audience"]
+
+	// RFC 8693 section 2.2.2, a client may only request tokens for its registered audiences
+	for _, aud := range audience {
+		if !slices.Contains(client.Audiences, aud) {
+			a.log.Error("audience is not allowed for client", slog.Any("client", client.ID), slog.Any("audience", aud))
+			a.writeOAuthError(w, errInvalidTarget, "audience is not allowed", http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	user := &models.User{
+		ID:        subject.User.ID,
+		Ip:        subject.User.Ip,
+		SessionID: subject.User.SessionID,
+		TokenID:   uuid.New(),
+		ClientID:  client.ID,
+		Scopes:    scopes,
+		Audience:  audience,
+		Actor:     actor,
+	}
+
+	// the exchanged token must not outlive the grant it is derived from
+	expiresIn := min(a.tokenTTL, time.Until(subject.ExpiresAt))
+
+	accessToken, err := a.jwt.IssueUntil(user, subject.ExpiresAt)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		a.writeOAuthError(w, errInvalidGrant, "subject token has expired", http.StatusBadRequest)
+
+		return
+	}
+
+	if err != nil {
+		a.log.Error("failed to generate access token", slog.Any("error", err.Error()))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	a.writeOAuth(w, TokenResp{
+		AccessToken:     accessToken,
+		IssuedTokenType: tokenTypeAccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(expiresIn.Seconds()),
+		Scope:           strings.Join(scopes, " "),
+	})
+
+	a.log.Info("token exchanged", slog.Any("client", client.ID), slog.Any("GUID", user.ID),
+		slog.Any("audience", user.Audience))
+}
+
+// exchangeScopes narrows the scopes of the subject token to those the client and
+// the user both hold, so a token without scopes is exchanged for one without scopes.
+func exchangeScopes(subject, stored *models.User, client *models.Client, requested string) ([]string, bool) {
+	allowed := slices.DeleteFunc(userScopes(stored, client.Scopes), func(scope string) bool {
+		return !slices.Contains(subject.Scopes, scope)
+	})
+
+	return requestScopes(allowed, requested)
+}
@@ -3,10 +3,7 @@ package auth
 import (
 	"auth/internal/models"
 	"context"
-	"encoding/base64"
-	"fmt"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"log/slog"
 	"net/http"
 	"regexp"
@@ -29,13 +26,7 @@ func (a *AuthHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	IPAddress := r.Header.Get("X-Real-Ip")
-	if IPAddress == "" {
-		IPAddress = r.Header.Get("X-Forwarded-For")
-	}
-	if IPAddress == "" {
-		IPAddress = r.RemoteAddr
-	}
+	IPAddress := realIP(r)
 
 	ID, err := uuid.Parse(guid)
 	if err != nil {
@@ -50,58 +41,65 @@ func (a *AuthHandler) Get(w http.ResponseWriter, r *http.Request) {
 		Ip: IPAddress,
 	}
 
-	token, err := a.jwt.Issue(user)
+	err = a.user.Add(context.Background(), user)
 	if err != nil {
-		a.log.Error("failed to generate access token", slog.Any("error", err.Error()))
+		a.log.Error("failed to add user", slog.Any("error", err.Error()))
 		a.writeError(w, "internal error", http.StatusInternalServerError)
 
 		return
 	}
 
-	refreshToken := fmt.Sprintf("%s %s", guid, IPAddress)
+	stored, err := a.user.GetByGUID(context.Background(), guid)
+	if err != nil {
+		a.log.Error("failed to get user", slog.Any("error", err.Error()))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
 
-	refreshTokenHash, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+		return
+	}
+
+	scopes, ok := requestScopes(stored.Scopes, query.Get("scope"))
+	if !ok {
+		a.log.Error("scope not granted to user", slog.Any("guid", guid), slog.Any("scope", query.Get("scope")))
+		a.writeError(w, "invalid scope", http.StatusBadRequest)
+
+		return
+	}
+
+	user.Scopes = scopes
+
+	session := &models.Session{
+		ID:        uuid.New(),
+		UserID:    ID,
+		Ip:        IPAddress,
+		UserAgent: r.UserAgent(),
+		Scopes:    scopes,
+	}
+
+	refreshToken, err := a.newRefreshToken(session)
 	if err != nil {
-		a.log.Error("failed to generate hash from refresh token", slog.Any("error", err.Error()))
+		a.log.Error("failed to generate refresh token", slog.Any("error", err.Error()))
 		a.writeError(w, "internal error", http.StatusInternalServerError)
 
 		return
 	}
 
-	user.Token = string(refreshTokenHash)
+	pairTokens(user, session)
 
-	err = a.user.Add(context.Background(), user)
+	err = a.session.Add(context.Background(), session)
 	if err != nil {
-		a.log.Error("failed to add user", slog.Any("error", err.Error()))
+		a.log.Error("failed to add session", slog.Any("error", err.Error()))
 		a.writeError(w, "internal error", http.StatusInternalServerError)
 
 		return
 	}
 
-	accessCookie := generateCookie(
-		AccessToken,
-		token,
-		"/",
-		"",
-		a.tokenTTL,
-		false,
-		false,
-		http.SameSiteStrictMode,
-	)
-
-	refreshCookie := generateCookie(
-		RefreshToken,
-		base64.URLEncoding.EncodeToString([]byte(refreshToken)),
-		"/",
-		"",
-		a.sessionTTL,
-		false,
-		true,
-		http.SameSiteStrictMode,
-	)
-
-	http.SetCookie(w, &accessCookie)
-	http.SetCookie(w, &refreshCookie)
+	err = a.setTokens(w, user, refreshToken)
+	if err != nil {
+		a.log.Error("failed to generate access token", slog.Any("error", err.Error()))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
 
 	resp := GetTokensResp{
 		ID: guid,
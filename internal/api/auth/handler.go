@@ -4,10 +4,14 @@ import (
 	"auth/internal/models"
 	"auth/internal/token"
 	"auth/internal/usecase"
+	"auth/pkg/jwt"
+	"auth/pkg/paseto"
 	"context"
 	"encoding/json"
+	"github.com/google/uuid"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -15,6 +19,10 @@ type AuthHandler struct {
 	log         *slog.Logger
 	jwt         JWTService
 	user        UserUseCase
+	session     SessionUseCase
+	client      ClientUseCase
+	code        CodeUseCase
+	device      DeviceUseCase
 	tokenTTL    time.Duration
 	sessionTTL  time.Duration
 	tokenLength int
@@ -27,18 +35,73 @@ type UserUseCase interface {
 	GetByGUID(ctx context.Context, GUID string) (*models.User, error)
 }
 
+var _ SessionUseCase = (*usecase.SessionUseCase)(nil)
+
+type SessionUseCase interface {
+	Add(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, ID string) (*models.Session, error)
+	GetByTokenID(ctx context.Context, tokenID string) (*models.Session, error)
+	Rotate(ctx context.Context, session *models.Session, consumed *models.RefreshToken) error
+	GetConsumedToken(ctx context.Context, ID string) (*models.RefreshToken, error)
+	Revoke(ctx context.Context, ID string) error
+	RevokeByUser(ctx context.Context, userID string) ([]*models.Session, error)
+}
+
+var _ ClientUseCase = (*usecase.ClientUseCase)(nil)
+
+type ClientUseCase interface {
+	GetByID(ctx context.Context, ID string) (*models.Client, error)
+}
+
+var _ CodeUseCase = (*usecase.CodeUseCase)(nil)
+
+type CodeUseCase interface {
+	Add(ctx context.Context, code *models.AuthorizationCode) error
+	Consume(ctx context.Context, code string) (*models.AuthorizationCode, error)
+}
+
+var _ DeviceUseCase = (*usecase.DeviceUseCase)(nil)
+
+type DeviceUseCase interface {
+	Add(ctx context.Context, device *models.DeviceCode) error
+	GetByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error)
+	Poll(ctx context.Context, code string) (*models.DeviceCode, error)
+	SlowDown(ctx context.Context, code string, step time.Duration) error
+	Approve(ctx context.Context, userCode string, userID uuid.UUID, status models.DeviceCodeStatus) error
+	Consume(ctx context.Context, code string) error
+}
+
 var _ JWTService = (*token.Service)(nil)
 
 type JWTService interface {
 	Issue(user *models.User) (string, error)
-	ParseUser(accessToken string) (*models.User, error)
+	IssueUntil(user *models.User, expiresAt time.Time) (string, error)
+	IssueClient(client *models.Client, tokenID uuid.UUID, scopes []string) (string, error)
+	ParseUser(ctx context.Context, accessToken string) (*models.User, error)
+	ParseAccessToken(ctx context.Context, accessToken string) (*models.AccessToken, error)
+	ParseUserWithoutValidation(accessToken string) (*models.User, error)
+	Revoke(ctx context.Context, tokenID uuid.UUID) error
+	RevokeUntil(ctx context.Context, tokenID uuid.UUID, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID uuid.UUID) error
+	IssueIDToken(user *models.User, nonce string, authTime time.Time) (string, error)
+	JWKS() jwt.JSONWebKeySet
+	PasetoKeys() paseto.KeySet
+	Issuer() string
+	IDTokenAlgorithms() []string
 }
 
-func NewAuthHandler(l *slog.Logger, j *token.Service, u *usecase.UserUseCase, tTTL, sTTL time.Duration, tl int) *AuthHandler {
+func NewAuthHandler(
+	l *slog.Logger, j *token.Service, u *usecase.UserUseCase, s *usecase.SessionUseCase, c *usecase.ClientUseCase,
+	ac *usecase.CodeUseCase, dc *usecase.DeviceUseCase, tTTL, sTTL time.Duration, tl int,
+) *AuthHandler {
 	return &AuthHandler{
 		log:         l,
 		jwt:         j,
 		user:        u,
+		session:     s,
+		client:      c,
+		code:        ac,
+		device:      dc,
 		tokenTTL:    tTTL,
 		sessionTTL:  sTTL,
 		tokenLength: tl,
@@ -77,6 +140,39 @@ func (a *AuthHandler) writeError(w http.ResponseWriter, msg string, statusCode i
 	}
 }
 
+// realIP returns the client address as seen by the proxy in front of the service.
+func realIP(r *http.Request) string {
+	IPAddress := r.Header.Get("X-Real-Ip")
+	if IPAddress == "" {
+		IPAddress = r.Header.Get("X-Forwarded-For")
+	}
+	if IPAddress == "" {
+		IPAddress = r.RemoteAddr
+	}
+
+	return IPAddress
+}
+
+// securityEvent reports suspicious activity, such as a replayed refresh token,
+// so it can be picked up by alerting separately from ordinary errors.
+func (a *AuthHandler) securityEvent(event string, attrs ...any) {
+	a.log.Warn("security event", append([]any{slog.String("event", event)}, attrs...)...)
+}
+
+// accessToken reads the access token from the Authorization header or the cookie.
+func accessToken(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer "), true
+	}
+
+	cookie, err := r.Cookie(AccessToken)
+	if err != nil {
+		return "", false
+	}
+
+	return cookie.Value, true
+}
+
 func generateCookie(
 	name, value, path, domain string, expiresIn time.Duration, secure, httpOnly bool, sameSite http.SameSite,
 ) http.Cookie {
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"auth/internal/models"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IntrospectResp is the RFC 7662 introspection response. Only `active` is
+// set for tokens that are invalid, expired or revoked.
+type IntrospectResp struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	Sid       string `json:"sid,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	Ip        string `json:"ip,omitempty"`
+}
+
+// Introspect tells services that cannot verify access tokens locally whether
+// the token is active. Besides the signature and expiry the token must not be
+// on the denylist and its session must not be revoked.
+func (a *AuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	client, err := a.authenticateClient(r)
+	if errors.Is(err, ErrInvalidClient) || err == nil && client.Public {
+		a.log.Error("invalid client credentials")
+		a.writeOAuthError(w, errInvalidClient, "client authentication failed", http.StatusUnauthorized)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to authenticate client", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	accessToken := r.PostFormValue("token")
+	if accessToken == "" {
+		a.log.Error("no token to introspect", slog.Any("client", client.ID))
+		a.writeOAuthError(w, errInvalidRequest, "token is required", http.StatusBadRequest)
+
+		return
+	}
+
+	token, err := a.jwt.ParseAccessToken(context.Background(), accessToken)
+	if err != nil {
+		a.log.Info("inactive token introspected", slog.Any("client", client.ID), slog.Any("error", err))
+		a.writeOAuth(w, IntrospectResp{Active: false})
+
+		return
+	}
+
+	resp := IntrospectResp{
+		Active:    true,
+		TokenType: "Bearer",
+		Sub:       token.Subject,
+		Exp:       token.ExpiresAt.Unix(),
+		Iat:       token.IssuedAt.Unix(),
+		Iss:       token.Issuer,
+		ClientID:  token.ClientID,
+		Scope:     strings.Join(token.Scopes, " "),
+		Jti:       token.TokenID.String(),
+	}
+
+	// tokens issued to a client have no session
+	if token.User != nil {
+		session, err := a.session.GetByID(context.Background(), token.User.SessionID.String())
+		if errors.Is(err, models.ErrNotFound) {
+			a.writeOAuth(w, IntrospectResp{Active: false})
+
+			return
+		}
+		if err != nil {
+			a.log.Error("failed to get session", slog.Any("error", err))
+			a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		if session.Revoked || time.Now().After(session.ExpiresAt) {
+			a.writeOAuth(w, IntrospectResp{Active: false})
+
+			return
+		}
+
+		resp.Sid = token.User.SessionID.String()
+		resp.Ip = token.User.Ip
+	}
+
+	a.writeOAuth(w, resp)
+
+	a.log.Info("token introspected", slog.Any("client", client.ID), slog.Any("sub", token.Subject))
+}
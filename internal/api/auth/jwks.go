@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (a *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+
+	a.writeSuccesful(w, a.jwt.JWKS())
+}
+
+// PasetoKeys publishes the key v4.public access tokens are verified with as PASERK.
+func (a *AuthHandler) PasetoKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+
+	a.writeSuccesful(w, a.jwt.PasetoKeys())
+}
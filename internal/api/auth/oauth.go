@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"auth/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// OAuth error codes, RFC 6749 section 5.2.
+const (
+	errInvalidRequest = "invalid_request"
+	errInvalidClient  = "invalid_client"
+	errInvalidGrant   = "invalid_grant"
+	errInvalidScope   = "invalid_scope"
+	errInvalidTarget  = "invalid_target"
+	errUnauthorized   = "unauthorized_client"
+	errUnsupported    = "unsupported_grant_type"
+	errResponseType   = "unsupported_response_type"
+	errAccessDenied   = "access_denied"
+	errLoginRequired  = "login_required"
+	errServerError    = "server_error"
+)
+
+// Device authorization errors, RFC 8628 section 3.5.
+const (
+	errAuthorizationPending = "authorization_pending"
+	errSlowDown             = "slow_down"
+	errExpiredToken         = "expired_token"
+)
+
+const (
+	grantRefreshToken      = "refresh_token"
+	grantClientCredentials = "client_credentials"
+	grantAuthorizationCode = "authorization_code"
+	grantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	grantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// Token type hints, RFC 7009 section 2.1.
+const (
+	hintAccessToken  = "access_token"
+	hintRefreshToken = "refresh_token"
+)
+
+var ErrInvalidClient = errors.New("invalid client credentials")
+
+// unknownClientHash is compared against when the client does not exist, so the
+// response time does not tell registered client IDs apart from unknown ones.
+var unknownClientHash, _ = bcrypt.GenerateFromPassword([]byte("unknown client"), bcrypt.DefaultCost)
+
+type OAuthErrorResp struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// writeOAuth writes a response of the OAuth endpoints, which must never be cached.
+func (a *AuthHandler) writeOAuth(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	a.writeSuccesful(w, data)
+}
+
+func (a *AuthHandler) writeOAuthError(w http.ResponseWriter, code, description string, statusCode int) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if statusCode == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	w.WriteHeader(statusCode)
+
+	resp, _ := json.Marshal(OAuthErrorResp{
+		Error:            code,
+		ErrorDescription: description,
+	})
+
+	_, err := w.Write(resp)
+	if err != nil {
+		a.log.Error("failed to write response", slog.Any("error", err.Error()))
+	}
+}
+
+// grantScopes checks the space separated scopes requested by the client against the
+// scopes it is registered with, all of them are granted when none are requested.
+func grantScopes(client *models.Client, requested string) ([]string, bool) {
+	return requestScopes(client.Scopes, requested)
+}
+
+// userScopes drops the scopes the user does not hold, RFC 6749 section 3.3 lets the
+// server grant fewer scopes than requested. A user without scopes gets none of the
+// client's scopes. openid only asks for the user's identity and is always kept.
+func userScopes(user *models.User, scopes []string) []string {
+	granted := make([]string, 0, len(scopes))
+
+	for _, scope := range scopes {
+		if scope == scopeOpenID || slices.Contains(user.Scopes, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	return granted
+}
+
+// requestScopes checks that the space separated requested scopes are a subset of
+// the allowed ones, all allowed scopes are returned when none are requested.
+func requestScopes(allowed []string, requested string) ([]string, bool) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return allowed, true
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return nil, false
+		}
+	}
+
+	return scopes, true
+}
+
+// authenticateClient checks the client credentials sent with HTTP Basic
+// authentication or as client_id and client_secret form parameters. Public
+// clients only identify themselves with client_id.
+func (a *AuthHandler) authenticateClient(r *http.Request) (*models.Client, error) {
+	clientID, secret, err := clientCredentials(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := a.client.GetByID(context.Background(), clientID)
+	if errors.Is(err, models.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(unknownClientHash, []byte(secret))
+
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Public {
+		return client, nil
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(client.Secret), []byte(secret)); err != nil {
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// clientCredentials reads the client ID and secret. Both are form-urlencoded
+// before they are put into the Basic authorization header, RFC 6749 section 2.3.1.
+func clientCredentials(r *http.Request) (string, string, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		return r.PostFormValue("client_id"), r.PostFormValue("client_secret"), nil
+	}
+
+	clientID, err := url.QueryUnescape(clientID)
+	if err != nil {
+		return "", "", ErrInvalidClient
+	}
+
+	secret, err = url.QueryUnescape(secret)
+	if err != nil {
+		return "", "", ErrInvalidClient
+	}
+
+	return clientID, secret, nil
+}
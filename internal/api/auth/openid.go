@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// scopeOpenID requests an ID token in the authorization code flow.
+const scopeOpenID = "openid"
+
+// DiscoveryResp is the OpenID Provider metadata, OpenID Connect Discovery 1.0 section 3.
+type DiscoveryResp struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type UserInfoResp struct {
+	Sub string `json:"sub"`
+}
+
+// Discovery describes the endpoints of the service. The issuer must be the URL
+// the service is reachable at, as clients fetch this document relative to it.
+func (a *AuthHandler) Discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := strings.TrimSuffix(a.jwt.Issuer(), "/")
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+
+	a.writeSuccesful(w, DiscoveryResp{
+		Issuer:                      a.jwt.Issuer(),
+		AuthorizationEndpoint:       issuer + "/oauth/authorize",
+		TokenEndpoint:               issuer + "/oauth/token",
+		UserinfoEndpoint:            issuer + "/userinfo",
+		JWKSURI:                     issuer + "/.well-known/jwks.json",
+		RevocationEndpoint:          issuer + "/oauth/revoke",
+		IntrospectionEndpoint:       issuer + "/oauth/introspect",
+		DeviceAuthorizationEndpoint: issuer + "/oauth/device_authorization",
+		ScopesSupported:             []string{scopeOpenID},
+		ResponseTypesSupported:      []string{"code"},
+		GrantTypesSupported: []string{grantAuthorizationCode, grantRefreshToken, grantClientCredentials,
+			grantDeviceCode, grantTokenExchange},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  a.jwt.IDTokenAlgorithms(),
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "sid"},
+	})
+}
+
+// UserInfo returns the claims about the user the access token was issued for.
+// Only tokens granted the openid scope are accepted.
+func (a *AuthHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	token, ok := accessToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		a.writeOAuthError(w, "invalid_token", "no access token", http.StatusUnauthorized)
+
+		return
+	}
+
+	user, err := a.jwt.ParseUser(context.Background(), token)
+	if err != nil {
+		a.log.Error("invalid access token", slog.Any("error", err))
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		a.writeOAuthError(w, "invalid_token", "", http.StatusUnauthorized)
+
+		return
+	}
+
+	if !slices.Contains(user.Scopes, scopeOpenID) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		a.writeOAuthError(w, "insufficient_scope", "", http.StatusForbidden)
+
+		return
+	}
+
+	a.writeOAuth(w, UserInfoResp{
+		Sub: user.ID.String(),
+	})
+}
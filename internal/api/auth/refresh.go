@@ -2,14 +2,16 @@ package auth
 
 import (
 	"auth/internal/api/email"
+	"auth/internal/models"
 	"context"
-	"encoding/base64"
 	"errors"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"log/slog"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 )
 
 func (a *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
@@ -33,23 +35,38 @@ func (a *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	IPAddress := r.Header.Get("X-Real-Ip")
-	if IPAddress == "" {
-		IPAddress = r.Header.Get("X-Forwarded-For")
-	}
-	if IPAddress == "" {
-		IPAddress = r.RemoteAddr
+	IPAddress := realIP(r)
+
+	tokenID, refreshToken, err := decodeRefreshToken(refreshTokenEncoded.Value)
+	if err != nil {
+		a.log.Error("invalid user token", slog.Any("error", err))
+		a.writeError(w, "invalid token", http.StatusBadRequest)
+
+		return
 	}
 
-	user, err := a.user.GetByGUID(context.Background(), guid)
+	session, err := a.session.GetByTokenID(context.Background(), tokenID.String())
+	if errors.Is(err, models.ErrNotFound) {
+		a.detectReuse(guid, tokenID.String(), refreshToken)
+		a.writeError(w, "invalid token", http.StatusBadRequest)
+
+		return
+	}
 	if err != nil {
-		a.log.Error("failed to get user by guid", slog.Any("error", err))
+		a.log.Error("failed to get session by token", slog.Any("error", err))
 		a.writeError(w, "internal error", http.StatusInternalServerError)
 
 		return
 	}
 
-	refreshToken, err := base64.StdEncoding.DecodeString(refreshTokenEncoded.Value)
+	if !strings.EqualFold(session.UserID.String(), guid) {
+		a.log.Error("refresh token belongs to another user", slog.Any("guid", guid))
+		a.writeError(w, "invalid token", http.StatusBadRequest)
+
+		return
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(session.Token), refreshToken)
 	if err != nil {
 		a.log.Error("invalid user token", slog.Any("error", err))
 		a.writeError(w, "invalid token", http.StatusBadRequest)
@@ -57,25 +74,151 @@ func (a *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Token), refreshToken)
+	// the access token of the pair is required, the cookie expires with the
+	// token, so once it is gone the client presents the token in the header
+	pairedToken, ok := accessToken(r)
+	if !ok {
+		a.log.Error("no access token in request", slog.Any("guid", guid))
+		a.writeError(w, "no token", http.StatusBadRequest)
+
+		return
+	}
+
+	pairedUser, err := a.jwt.ParseUserWithoutValidation(pairedToken)
 	if err != nil {
-		a.log.Error("invalid user token", slog.Any("error", err))
+		a.log.Error("invalid access token", slog.Any("error", err))
 		a.writeError(w, "invalid token", http.StatusBadRequest)
 
 		return
 	}
 
-	s := strings.Split(string(refreshToken), " ")
-	newIPAddress := s[1]
+	if pairedUser.SessionID != session.ID || pairedUser.TokenID != session.AccessTokenID {
+		a.securityEvent("token_pair_mismatch", slog.Any("GUID", guid), slog.Any("session", session.ID))
+		a.writeError(w, "invalid token", http.StatusBadRequest)
 
-	if IPAddress != newIPAddress {
-		a.log.Info("new ip address user", slog.Any("GUID", guid))
-		if err = email.SendEmailWarning(userEmail, IPAddress, newIPAddress); err != nil {
-			a.log.Error("failed to send email warning to user", slog.Any("id", guid), slog.Any("error", err))
-		}
+		return
+	}
+
+	if session.Revoked || time.Now().After(session.ExpiresAt) {
+		a.log.Error("session expired or revoked", slog.Any("guid", guid), slog.Any("session", session.ID))
+		a.writeError(w, "invalid token", http.StatusBadRequest)
+
+		return
+	}
+
+	user, newRefreshToken, err := a.rotateSession(session, IPAddress, r.UserAgent())
+	if errors.Is(err, ErrRefreshTokenReused) {
+		a.writeError(w, "invalid token", http.StatusBadRequest)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to rotate refresh token", slog.Any("error", err.Error()))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+
+	err = a.setTokens(w, user, newRefreshToken)
+	if err != nil {
+		a.log.Error("failed to generate access token", slog.Any("error", err.Error()))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
 
+		return
 	}
 
-	a.Get(w, r)
+	a.writeSuccesful(w, GetTokensResp{
+		ID: guid,
+	})
+
 	a.log.Info("successful refresh tokens to user", slog.Any("GUID", guid))
 }
+
+// rotateSession exchanges the current refresh token of the session for a new one
+// and pairs it with the access token about to be issued for the returned user.
+func (a *AuthHandler) rotateSession(session *models.Session, IPAddress, userAgent string) (*models.User, string, error) {
+	if IPAddress != session.Ip {
+		a.log.Info("new ip address user", slog.Any("GUID", session.UserID))
+		if err := email.SendEmailWarning(userEmail, session.Ip, IPAddress); err != nil {
+			a.log.Error("failed to send email warning to user", slog.Any("id", session.UserID), slog.Any("error", err))
+		}
+	}
+
+	consumed := &models.RefreshToken{
+		ID:        session.TokenID,
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		Token:     session.Token,
+		ExpiresAt: session.ExpiresAt,
+	}
+
+	session.Ip = IPAddress
+	session.UserAgent = userAgent
+
+	refreshToken, err := a.newRefreshToken(session)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user := &models.User{
+		ID:       session.UserID,
+		Ip:       IPAddress,
+		ClientID: session.ClientID,
+		Scopes:   session.Scopes,
+	}
+
+	pairTokens(user, session)
+
+	err = a.session.Rotate(context.Background(), session, consumed)
+	if errors.Is(err, models.ErrNotFound) {
+		// a concurrent request has already exchanged the same token
+		a.securityEvent("refresh_token_reuse", slog.Any("GUID", session.UserID), slog.Any("session", session.ID))
+		a.revokeSession(session.ID)
+
+		return nil, "", ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, refreshToken, nil
+}
+
+// detectReuse checks whether the presented token was already exchanged. A replay
+// means the token leaked, so the session with its whole token family is revoked
+// and both the attacker and the legitimate user have to log in again.
+// An empty guid skips the owner check when the caller does not know the user.
+func (a *AuthHandler) detectReuse(guid, tokenID string, refreshToken []byte) {
+	consumed, err := a.session.GetConsumedToken(context.Background(), tokenID)
+	if errors.Is(err, models.ErrNotFound) {
+		a.log.Error("unknown refresh token", slog.Any("guid", guid))
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to get consumed refresh token", slog.Any("error", err))
+
+		return
+	}
+
+	if guid != "" && !strings.EqualFold(consumed.UserID.String(), guid) ||
+		bcrypt.CompareHashAndPassword([]byte(consumed.Token), refreshToken) != nil {
+		a.log.Error("invalid user token", slog.Any("guid", guid))
+
+		return
+	}
+
+	a.securityEvent("refresh_token_reuse", slog.Any("GUID", guid), slog.Any("session", consumed.SessionID))
+	a.revokeSession(consumed.SessionID)
+}
+
+// revokeSession ends a compromised session, its access tokens are rejected from now on.
+func (a *AuthHandler) revokeSession(sessionID uuid.UUID) {
+	if err := a.session.Revoke(context.Background(), sessionID.String()); err != nil {
+		a.log.Error("failed to revoke session", slog.Any("session", sessionID), slog.Any("error", err))
+	}
+
+	if err := a.jwt.RevokeSession(context.Background(), sessionID); err != nil {
+		a.log.Error("failed to revoke session tokens", slog.Any("session", sessionID), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"auth/internal/models"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"net/http"
+)
+
+// OAuthRevoke revokes the session of an access or refresh token as described in
+// RFC 7009. Unknown and invalid tokens, as well as tokens of sessions started by
+// another client or with cookies, are answered with 200 and left untouched, so
+// the client cannot tell whether the token existed.
+func (a *AuthHandler) OAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	client, err := a.authenticateClient(r)
+	if errors.Is(err, ErrInvalidClient) {
+		a.log.Error("invalid client credentials")
+		a.writeOAuthError(w, errInvalidClient, "client authentication failed", http.StatusUnauthorized)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to authenticate client", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		a.log.Error("no token to revoke", slog.Any("client", client.ID))
+		a.writeOAuthError(w, errInvalidRequest, "token is required", http.StatusBadRequest)
+
+		return
+	}
+
+	lookups := []func(string) (*models.Session, error){a.sessionByAccessToken, a.sessionByRefreshToken}
+	if r.PostFormValue("token_type_hint") == hintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	var session *models.Session
+	for _, lookup := range lookups {
+		session, err = lookup(token)
+		if err == nil || !errors.Is(err, models.ErrNotFound) {
+			break
+		}
+	}
+
+	if errors.Is(err, models.ErrNotFound) {
+		a.revokeClientToken(w, client, token)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to find session of token", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	if session.ClientID != client.ID {
+		// RFC 7009 section 2.1, a client may only revoke its own tokens
+		a.log.Info("token of another client not revoked", slog.Any("client", client.ID),
+			slog.Any("session", session.ID))
+		a.writeOAuth(w, struct{}{})
+
+		return
+	}
+
+	if err = a.session.Revoke(context.Background(), session.ID.String()); err != nil {
+		a.log.Error("failed to revoke session", slog.Any("session", session.ID), slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	tokenIDs := []uuid.UUID{session.AccessTokenID}
+
+	// the revoked access token may be an older one of the session that is not expired yet
+	if user, err := a.jwt.ParseUserWithoutValidation(token); err == nil && user.TokenID != session.AccessTokenID {
+		tokenIDs = append(tokenIDs, user.TokenID)
+	}
+
+	for _, tokenID := range tokenIDs {
+		if err = a.jwt.Revoke(context.Background(), tokenID); err != nil {
+			a.log.Error("failed to revoke access token", slog.Any("error", err))
+			a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	if err = a.jwt.RevokeSession(context.Background(), session.ID); err != nil {
+		a.log.Error("failed to revoke session tokens", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	a.writeOAuth(w, struct{}{})
+
+	a.log.Info("session revoked", slog.Any("client", client.ID), slog.Any("GUID", session.UserID),
+		slog.Any("session", session.ID))
+}
+
+// revokeClientToken puts an access token issued to the client itself on the denylist,
+// such tokens have no session. Any other token is unknown and ignored.
+func (a *AuthHandler) revokeClientToken(w http.ResponseWriter, client *models.Client, token string) {
+	accessToken, err := a.jwt.ParseAccessToken(context.Background(), token)
+	if err != nil || accessToken.User != nil || accessToken.ClientID != client.ID {
+		a.log.Info("unknown token revoked", slog.Any("client", client.ID))
+		a.writeOAuth(w, struct{}{})
+
+		return
+	}
+
+	if err = a.jwt.RevokeUntil(context.Background(), accessToken.TokenID, accessToken.ExpiresAt); err != nil {
+		a.log.Error("failed to revoke access token", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	a.writeOAuth(w, struct{}{})
+
+	a.log.Info("client token revoked", slog.Any("client", client.ID))
+}
+
+// sessionByAccessToken finds the session of an access token, the token may already be expired.
+func (a *AuthHandler) sessionByAccessToken(token string) (*models.Session, error) {
+	user, err := a.jwt.ParseUserWithoutValidation(token)
+	if err != nil {
+		return nil, models.ErrNotFound
+	}
+
+	return a.session.GetByID(context.Background(), user.SessionID.String())
+}
+
+// sessionByRefreshToken finds the session whose current refresh token is the given one.
+func (a *AuthHandler) sessionByRefreshToken(token string) (*models.Session, error) {
+	tokenID, secret, err := decodeRefreshToken(token)
+	if err != nil {
+		return nil, models.ErrNotFound
+	}
+
+	session, err := a.session.GetByTokenID(context.Background(), tokenID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(session.Token), secret); err != nil {
+		return nil, models.ErrNotFound
+	}
+
+	return session, nil
+}
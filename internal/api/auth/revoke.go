@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"auth/internal/models"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+)
+
+type RevokeResp struct {
+	Sessions []string `json:"sessions"`
+}
+
+// Revoke logs out of the current session. The access token may already be
+// expired, only its signature is checked to find the session.
+func (a *AuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	token, ok := accessToken(r)
+	if !ok {
+		a.log.Error("no access token in request")
+		a.writeError(w, "no token", http.StatusUnauthorized)
+
+		return
+	}
+
+	user, err := a.jwt.ParseUserWithoutValidation(token)
+	if err != nil {
+		a.log.Error("invalid access token", slog.Any("error", err))
+		a.writeError(w, "invalid token", http.StatusUnauthorized)
+
+		return
+	}
+
+	if !a.revokeSessionTokens(w, user.SessionID, user.TokenID) {
+		return
+	}
+
+	a.clearTokens(w)
+
+	a.writeSuccesful(w, RevokeResp{
+		Sessions: []string{user.SessionID.String()},
+	})
+
+	a.log.Info("user logged out", slog.Any("GUID", user.ID), slog.Any("session", user.SessionID))
+}
+
+// RevokeSession ends one of the user's sessions, e.g. a forgotten login on another device.
+func (a *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		a.log.Error("invalid session id", slog.Any("id", r.PathValue("id")))
+		a.writeError(w, "invalid session id", http.StatusBadRequest)
+
+		return
+	}
+
+	session, err := a.session.GetByID(context.Background(), sessionID.String())
+	if errors.Is(err, models.ErrNotFound) || err == nil && session.UserID != user.ID {
+		a.log.Error("session not found", slog.Any("GUID", user.ID), slog.Any("session", sessionID))
+		a.writeError(w, "session not found", http.StatusNotFound)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to get session", slog.Any("error", err))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+
+	if !a.revokeSessionTokens(w, session.ID, session.AccessTokenID) {
+		return
+	}
+
+	if session.ID == user.SessionID {
+		a.clearTokens(w)
+	}
+
+	a.writeSuccesful(w, RevokeResp{
+		Sessions: []string{session.ID.String()},
+	})
+
+	a.log.Info("session revoked", slog.Any("GUID", user.ID), slog.Any("session", session.ID))
+}
+
+// RevokeAllSessions logs the user out on every device.
+func (a *AuthHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := a.session.RevokeByUser(context.Background(), user.ID.String())
+	if err != nil {
+		a.log.Error("failed to revoke sessions", slog.Any("error", err))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+
+	resp := RevokeResp{
+		Sessions: make([]string, 0, len(sessions)),
+	}
+
+	tokenIDs := []uuid.UUID{user.TokenID}
+	sessionIDs := []uuid.UUID{user.SessionID}
+
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, session.ID.String())
+		tokenIDs = append(tokenIDs, session.AccessTokenID)
+		sessionIDs = append(sessionIDs, session.ID)
+	}
+
+	for _, tokenID := range tokenIDs {
+		if err = a.jwt.Revoke(context.Background(), tokenID); err != nil {
+			a.log.Error("failed to revoke access token", slog.Any("error", err))
+			a.writeError(w, "internal error", http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err = a.jwt.RevokeSession(context.Background(), sessionID); err != nil {
+			a.log.Error("failed to revoke session tokens", slog.Any("error", err))
+			a.writeError(w, "internal error", http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	a.clearTokens(w)
+
+	a.writeSuccesful(w, resp)
+
+	a.log.Info("all sessions revoked", slog.Any("GUID", user.ID), slog.Any("count", len(sessions)))
+}
+
+// authenticate parses the access token of the request and writes the error response if it is invalid.
+func (a *AuthHandler) authenticate(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	token, ok := accessToken(r)
+	if !ok {
+		a.log.Error("no access token in request")
+		a.writeError(w, "no token", http.StatusUnauthorized)
+
+		return nil, false
+	}
+
+	user, err := a.jwt.ParseUser(context.Background(), token)
+	if err != nil {
+		a.log.Error("invalid access token", slog.Any("error", err))
+		a.writeError(w, "invalid token", http.StatusUnauthorized)
+
+		return nil, false
+	}
+
+	return user, true
+}
+
+// revokeSessionTokens revokes the session, which invalidates its refresh token,
+// and puts the paired access token as well as the session itself on the
+// denylist, so tokens exchanged from it are rejected too.
+func (a *AuthHandler) revokeSessionTokens(w http.ResponseWriter, sessionID, accessTokenID uuid.UUID) bool {
+	if err := a.session.Revoke(context.Background(), sessionID.String()); err != nil {
+		a.log.Error("failed to revoke session", slog.Any("session", sessionID), slog.Any("error", err))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return false
+	}
+
+	if err := a.jwt.Revoke(context.Background(), accessTokenID); err != nil {
+		a.log.Error("failed to revoke access token", slog.Any("error", err))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return false
+	}
+
+	if err := a.jwt.RevokeSession(context.Background(), sessionID); err != nil {
+		a.log.Error("failed to revoke session tokens", slog.Any("error", err))
+		a.writeError(w, "internal error", http.StatusInternalServerError)
+
+		return false
+	}
+
+	return true
+}
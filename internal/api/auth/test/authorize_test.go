@@ -0,0 +1,380 @@
+package test
+
+import (
+	"auth/internal/api/auth"
+	"auth/internal/models"
+	"auth/internal/usecase/repo/postgres"
+	"auth/pkg/jwt"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const (
+	redirectURI  = "https://app.example.com/callback"
+	codeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// addPublicTestClient registers a client without secret, like a SPA or a mobile app.
+func addPublicTestClient(t *testing.T, db *sql.DB, scopes ...string) string {
+	client := &models.Client{
+		ID:           "app-" + uuid.NewString(),
+		Name:         "test app",
+		Scopes:       scopes,
+		RedirectURIs: []string{redirectURI},
+		Public:       true,
+	}
+
+	assert.NoError(t, postgres.NewClientRepo(db).Add(context.Background(), client))
+
+	return client.ID
+}
+
+// setUserScopes grants the user the scopes clients may request on their behalf.
+func setUserScopes(t *testing.T, db *sql.DB, guid string, scopes ...string) {
+	assert.NoError(t, postgres.NewUserRepo(db).SetScopes(context.Background(), guid, scopes))
+}
+
+func codeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func TestAuthHandler_AuthorizationCodeFunctional(t *testing.T) {
+	storagePath := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable", POSTGRES_USER,
+		POSTGRES_PASSWORD, ADDRESS, DB)
+
+	db, err := sql.Open("postgres", storagePath)
+	if err != nil {
+		assert.NoError(t, err)
+	}
+
+	defer db.Close()
+
+	authHandler := testAuthHandler(t, db)
+
+	r := http.NewServeMux()
+	r.HandleFunc("GET /token.get/", authHandler.Get)
+	r.HandleFunc("GET /oauth/authorize", authHandler.Authorize)
+	r.HandleFunc("POST /oauth/token", authHandler.Token)
+	r.HandleFunc("GET /userinfo", authHandler.UserInfo)
+	r.HandleFunc("GET /.well-known/jwks.json", authHandler.JWKS)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	noRedirect := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	clientID := addPublicTestClient(t, db, "openid", "profile", "orders:read")
+
+	guid := uuid.New().String()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.get/?guid=%s", server.URL, guid), nil)
+	req.Header.Set("X-Real-Ip", "127.0.0.1")
+
+	login, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	login.Body.Close()
+
+	accessToken := cookieValue(login.Cookies(), auth.AccessToken)
+
+	setUserScopes(t, db, guid, "profile")
+
+	authorize := func(params url.Values, accessToken string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/oauth/authorize?"+params.Encode(), nil)
+		if accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+
+		resp, err := noRedirect.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return resp
+	}
+
+	authorizeParams := func() url.Values {
+		return url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientID},
+			"redirect_uri":          {redirectURI},
+			"scope":                 {"profile"},
+			"state":                 {"xyz"},
+			"code_challenge":        {codeChallenge(codeVerifier)},
+			"code_challenge_method": {"S256"},
+		}
+	}
+
+	exchange := func(code, verifier string) (*http.Response, auth.TokenResp, auth.OAuthErrorResp) {
+		resp, err := http.PostForm(server.URL+"/oauth/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {clientID},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		})
+		assert.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var (
+			result   auth.TokenResp
+			oauthErr auth.OAuthErrorResp
+		)
+
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		} else {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&oauthErr))
+		}
+
+		return resp, result, oauthErr
+	}
+
+	codeFromRedirect := func(resp *http.Response) string {
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+		location, err := url.Parse(resp.Header.Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, "xyz", location.Query().Get("state"))
+
+		return location.Query().Get("code")
+	}
+
+	t.Run("Full flow", func(t *testing.T) {
+		code := codeFromRedirect(authorize(authorizeParams(), accessToken))
+		assert.NotEmpty(t, code)
+
+		resp, result, _ := exchange(code, codeVerifier)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEmpty(t, result.AccessToken)
+		assert.NotEmpty(t, result.RefreshToken)
+		assert.Equal(t, "profile", result.Scope)
+
+		user, err := testJWTService(t).ParseUser(context.Background(), result.AccessToken)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, guid, user.ID.String())
+		assert.Equal(t, clientID, user.ClientID)
+
+		resp, err = http.PostForm(server.URL+"/oauth/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {clientID},
+			"refresh_token": {result.RefreshToken},
+		})
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("OpenID Connect", func(t *testing.T) {
+		params := authorizeParams()
+		params.Set("scope", "openid profile")
+		params.Set("nonce", "n-0S6_WzA2Mj")
+
+		resp, result, _ := exchange(codeFromRedirect(authorize(params, accessToken)), codeVerifier)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEmpty(t, result.IDToken)
+
+		// the client verifies the ID token with the published keys only
+		verifier, err := jwt.NewVerifier(jwt.NewVerifierConfig().
+			SetJWKSURL(server.URL + "/.well-known/jwks.json").
+			SetIssuer(issuer))
+		assert.NoError(t, err)
+
+		claims, err := verifier.ParseTokenClaims(result.IDToken)
+		assert.NoError(t, err)
+
+		assert.Equal(t, guid, claims["sub"])
+		assert.Equal(t, clientID, claims["aud"])
+		assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+		assert.NotEmpty(t, claims["auth_time"])
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+result.AccessToken)
+
+		resp, err = http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var userInfo auth.UserInfoResp
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&userInfo))
+		assert.Equal(t, guid, userInfo.Sub)
+	})
+
+	t.Run("Scope the user lacks", func(t *testing.T) {
+		params := authorizeParams()
+		params.Set("scope", "openid profile orders:read")
+
+		resp, result, _ := exchange(codeFromRedirect(authorize(params, accessToken)), codeVerifier)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "openid profile", result.Scope, "orders:read is narrowed away")
+
+		user, err := testJWTService(t).ParseUser(context.Background(), result.AccessToken)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []string{"openid", "profile"}, user.Scopes)
+	})
+
+	t.Run("User without scopes", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.get/?guid=%s", server.URL, uuid.New()), nil)
+		req.Header.Set("X-Real-Ip", "127.0.0.1")
+
+		login, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		login.Body.Close()
+
+		params := authorizeParams()
+		params.Del("scope")
+
+		resp, result, _ := exchange(codeFromRedirect(authorize(params, cookieValue(login.Cookies(), auth.AccessToken))),
+			codeVerifier)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "openid", result.Scope, "none of the client's scopes are granted")
+	})
+
+	t.Run("Userinfo without openid scope", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "insufficient_scope")
+	})
+
+	t.Run("Replayed code revokes session", func(t *testing.T) {
+		code := codeFromRedirect(authorize(authorizeParams(), accessToken))
+
+		resp, result, _ := exchange(code, codeVerifier)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, _, oauthErr := exchange(code, codeVerifier)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", oauthErr.Error)
+
+		resp, err := http.PostForm(server.URL+"/oauth/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {clientID},
+			"refresh_token": {result.RefreshToken},
+		})
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Wrong code verifier", func(t *testing.T) {
+		code := codeFromRedirect(authorize(authorizeParams(), accessToken))
+
+		resp, _, oauthErr := exchange(code, "wrong-verifier-wrong-verifier-wrong-verifier-0")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", oauthErr.Error)
+	})
+
+	t.Run("Missing PKCE", func(t *testing.T) {
+		params := authorizeParams()
+		params.Del("code_challenge")
+
+		resp := authorize(params, accessToken)
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+		location, _ := url.Parse(resp.Header.Get("Location"))
+		assert.Equal(t, "invalid_request", location.Query().Get("error"))
+		assert.Empty(t, location.Query().Get("code"))
+	})
+
+	t.Run("Plain code challenge method", func(t *testing.T) {
+		params := authorizeParams()
+		params.Set("code_challenge_method", "plain")
+
+		location, _ := url.Parse(authorize(params, accessToken).Header.Get("Location"))
+		assert.Equal(t, "invalid_request", location.Query().Get("error"))
+	})
+
+	t.Run("Unregistered redirect uri", func(t *testing.T) {
+		params := authorizeParams()
+		params.Set("redirect_uri", "https://evil.example.com/callback")
+
+		resp := authorize(params, accessToken)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Location"))
+	})
+
+	tokenStatus := func(form url.Values) int {
+		form.Set("client_id", clientID)
+
+		resp, err := http.PostForm(server.URL+"/oauth/token", form)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	t.Run("Omitted redirect uri", func(t *testing.T) {
+		params := authorizeParams()
+		params.Del("redirect_uri")
+
+		status := tokenStatus(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {codeFromRedirect(authorize(params, accessToken))},
+			"code_verifier": {codeVerifier},
+		})
+		assert.Equal(t, http.StatusOK, status, "redirect_uri is required only if it was sent to authorize")
+	})
+
+	t.Run("Mismatched redirect uri", func(t *testing.T) {
+		status := tokenStatus(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {codeFromRedirect(authorize(authorizeParams(), accessToken))},
+			"redirect_uri":  {"https://app.example.com/other"},
+			"code_verifier": {codeVerifier},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("Public client with refresh token of a cookie session", func(t *testing.T) {
+		status := tokenStatus(url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {cookieValue(login.Cookies(), auth.RefreshToken)},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("Not logged in", func(t *testing.T) {
+		location, _ := url.Parse(authorize(authorizeParams(), "").Header.Get("Location"))
+		assert.Equal(t, "login_required", location.Query().Get("error"))
+	})
+
+	t.Run("Public client cannot use client credentials", func(t *testing.T) {
+		resp, err := http.PostForm(server.URL+"/oauth/token", url.Values{
+			"grant_type": {"client_credentials"},
+			"client_id":  {clientID},
+		})
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"auth/internal/api/auth"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const deviceGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+func TestAuthHandler_DeviceFunctional(t *testing.T) {
+	storagePath := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable", POSTGRES_USER,
+		POSTGRES_PASSWORD, ADDRESS, DB)
+
+	db, err := sql.Open("postgres", storagePath)
+	if err != nil {
+		assert.NoError(t, err)
+	}
+
+	defer db.Close()
+
+	authHandler := testAuthHandler(t, db)
+
+	r := http.NewServeMux()
+	r.HandleFunc("GET /token.get/", authHandler.Get)
+	r.HandleFunc("POST /oauth/token", authHandler.Token)
+	r.HandleFunc("POST /oauth/device_authorization", authHandler.DeviceAuthorization)
+	r.HandleFunc("GET /device", authHandler.Device)
+	r.HandleFunc("POST /device", authHandler.ApproveDevice)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	clientID := addPublicTestClient(t, db, "tv")
+
+	login := func(guid string) string {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.get/?guid=%s", server.URL, guid), nil)
+		req.Header.Set("X-Real-Ip", "127.0.0.1")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return cookieValue(resp.Cookies(), auth.AccessToken)
+	}
+
+	guid := uuid.NewString()
+	accessToken := login(guid)
+
+	setUserScopes(t, db, guid, "tv")
+
+	start := func() auth.DeviceAuthorizationResp {
+		resp, err := http.PostForm(server.URL+"/oauth/device_authorization", url.Values{
+			"client_id": {clientID},
+			"scope":     {"tv"},
+		})
+		assert.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result auth.DeviceAuthorizationResp
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+
+		return result
+	}
+
+	poll := func(deviceCode string) (int, auth.TokenResp, auth.OAuthErrorResp) {
+		resp, err := http.PostForm(server.URL+"/oauth/token", url.Values{
+			"grant_type":  {deviceGrant},
+			"client_id":   {clientID},
+			"device_code": {deviceCode},
+		})
+		assert.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var (
+			result   auth.TokenResp
+			oauthErr auth.OAuthErrorResp
+		)
+
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		} else {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&oauthErr))
+		}
+
+		return resp.StatusCode, result, oauthErr
+	}
+
+	// waitInterval lets the next poll through without sleeping for the poll interval
+	waitInterval := func() {
+		_, err := db.Exec("UPDATE device_codes SET last_polled_at = NULL")
+		assert.NoError(t, err)
+	}
+
+	decideAs := func(accessToken, userCode, action string) int {
+		form := url.Values{"user_code": {userCode}, "action": {action}}
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/device", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	decide := func(userCode, action string) int {
+		return decideAs(accessToken, userCode, action)
+	}
+
+	t.Run("Approved device", func(t *testing.T) {
+		device := start()
+		assert.NotEmpty(t, device.DeviceCode)
+		assert.Regexp(t, `^[A-Z]{4}-[A-Z]{4}$`, device.UserCode)
+		assert.Equal(t, int64(5), device.Interval)
+
+		status, _, oauthErr := poll(device.DeviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "authorization_pending", oauthErr.Error)
+
+		status, _, oauthErr = poll(device.DeviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "slow_down", oauthErr.Error)
+
+		assert.Equal(t, issuer+"/device", device.VerificationURI)
+		assert.True(t, strings.HasSuffix(device.VerificationURIComplete, "/device?user_code="+device.UserCode))
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/device?user_code="+device.UserCode, nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+
+		var shown auth.DeviceResp
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&shown))
+		resp.Body.Close()
+		assert.Equal(t, clientID, shown.ClientID)
+
+		assert.Equal(t, http.StatusOK, decide(strings.ToLower(device.UserCode), "approve"))
+
+		waitInterval()
+
+		status, result, _ := poll(device.DeviceCode)
+		assert.Equal(t, http.StatusOK, status)
+		assert.NotEmpty(t, result.AccessToken)
+		assert.NotEmpty(t, result.RefreshToken)
+		assert.Equal(t, "tv", result.Scope)
+
+		waitInterval()
+
+		status, _, oauthErr = poll(device.DeviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_grant", oauthErr.Error)
+	})
+
+	t.Run("Approved by a user without the scope", func(t *testing.T) {
+		device := start()
+
+		assert.Equal(t, http.StatusOK, decideAs(login(uuid.NewString()), device.UserCode, "approve"))
+
+		status, result, _ := poll(device.DeviceCode)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, result.Scope, "tv is narrowed away")
+	})
+
+	t.Run("Denied device", func(t *testing.T) {
+		device := start()
+
+		assert.Equal(t, http.StatusOK, decide(device.UserCode, "deny"))
+		assert.Equal(t, http.StatusBadRequest, decide(device.UserCode, "approve"), "decision is final")
+
+		status, _, oauthErr := poll(device.DeviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "access_denied", oauthErr.Error)
+	})
+
+	t.Run("Unknown user code", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, decide("BCDF-GHJK", "approve"))
+	})
+}
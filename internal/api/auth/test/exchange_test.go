@@ -0,0 +1,370 @@
+package test
+
+import (
+	"auth/internal/api/auth"
+	"auth/internal/models"
+	"auth/internal/token"
+	"auth/internal/usecase/repo/postgres"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const (
+	exchangeGrant   = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+func TestAuthHandler_TokenExchangeFunctional(t *testing.T) {
+	storagePath := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable", POSTGRES_USER,
+		POSTGRES_PASSWORD, ADDRESS, DB)
+
+	db, err := sql.Open("postgres", storagePath)
+	if err != nil {
+		assert.NoError(t, err)
+	}
+
+	defer db.Close()
+
+	authHandler := testAuthHandler(t, db)
+
+	r := http.NewServeMux()
+	r.HandleFunc("GET /token.get/", authHandler.Get)
+	r.HandleFunc("POST /token.revoke/", authHandler.Revoke)
+	r.HandleFunc("POST /oauth/token", authHandler.Token)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("gateway-secret"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+
+	gateway := &models.Client{
+		ID:        "gateway-" + uuid.NewString(),
+		Secret:    string(hash),
+		Name:      "gateway",
+		Scopes:    []string{"orders:read", "orders:write"},
+		Audiences: []string{"https://orders.example.com"},
+	}
+	assert.NoError(t, postgres.NewClientRepo(db).Add(context.Background(), gateway))
+
+	gatewayID := gateway.ID
+	publicID := addPublicTestClient(t, db, "orders:read")
+
+	guid := uuid.New()
+
+	login := func(guid uuid.UUID) string {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.get/?guid=%s", server.URL, guid), nil)
+		req.Header.Set("X-Real-Ip", "127.0.0.1")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return cookieValue(resp.Cookies(), auth.AccessToken)
+	}
+
+	jwtService := testJWTService(t)
+
+	// scoped issues a subject token granted exactly the given scopes
+	scoped := func(guid uuid.UUID, scopes ...string) string {
+		accessToken, err := jwtService.Issue(&models.User{
+			ID:        guid,
+			Ip:        "127.0.0.1",
+			SessionID: uuid.New(),
+			TokenID:   uuid.New(),
+			Scopes:    scopes,
+		})
+		assert.NoError(t, err)
+
+		return accessToken
+	}
+
+	login(guid)
+
+	setUserScopes(t, db, guid.String(), "orders:read")
+
+	accessToken := scoped(guid, "orders:read", "orders:write")
+
+	exchange := func(form url.Values) (int, auth.TokenResp, auth.OAuthErrorResp) {
+		form.Set("grant_type", exchangeGrant)
+
+		resp, err := http.PostForm(server.URL+"/oauth/token", form)
+		assert.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var (
+			result   auth.TokenResp
+			oauthErr auth.OAuthErrorResp
+		)
+
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		} else {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&oauthErr))
+		}
+
+		return resp.StatusCode, result, oauthErr
+	}
+
+	var downscoped string
+
+	t.Run("Downscoped token", func(t *testing.T) {
+		status, result, _ := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {accessToken},
+			"subject_token_type": {accessTokenType},
+			"audience":           {"https://orders.example.com"},
+			"scope":              {"orders:read"},
+		})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, accessTokenType, result.IssuedTokenType)
+		assert.Equal(t, "orders:read", result.Scope)
+		assert.Empty(t, result.RefreshToken)
+
+		user, err := jwtService.ParseUser(context.Background(), result.AccessToken)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, guid, user.ID)
+		assert.Equal(t, gatewayID, user.ClientID)
+		assert.Equal(t, []string{"orders:read"}, user.Scopes)
+		assert.Equal(t, []string{"https://orders.example.com"}, user.Audience)
+		assert.Equal(t, gatewayID, user.Actor.Subject)
+		assert.Nil(t, user.Actor.Actor)
+
+		downscoped = result.AccessToken
+	})
+
+	t.Run("Scope cannot be widened", func(t *testing.T) {
+		status, _, oauthErr := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {downscoped},
+			"subject_token_type": {accessTokenType},
+			"scope":              {"orders:write"},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_scope", oauthErr.Error)
+	})
+
+	t.Run("Subject token without scopes", func(t *testing.T) {
+		status, result, _ := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {scoped(guid)},
+			"subject_token_type": {accessTokenType},
+		})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, result.Scope, "scopes of the client and the user must not be added")
+
+		status, _, oauthErr := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {scoped(guid)},
+			"subject_token_type": {accessTokenType},
+			"scope":              {"orders:read"},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_scope", oauthErr.Error)
+	})
+
+	t.Run("Scope the user lacks", func(t *testing.T) {
+		status, _, oauthErr := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {accessToken},
+			"subject_token_type": {accessTokenType},
+			"scope":              {"orders:write"},
+		})
+		assert.Equal(t, http.StatusBadRequest, status, "the client and the token hold orders:write, the user does not")
+		assert.Equal(t, "invalid_scope", oauthErr.Error)
+	})
+
+	t.Run("Delegation chain", func(t *testing.T) {
+		status, result, _ := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {downscoped},
+			"subject_token_type": {accessTokenType},
+		})
+		assert.Equal(t, http.StatusOK, status)
+
+		user, err := jwtService.ParseUser(context.Background(), result.AccessToken)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []string{"orders:read"}, user.Scopes)
+		assert.Equal(t, gatewayID, user.Actor.Subject)
+		assert.Equal(t, gatewayID, user.Actor.Actor.Subject)
+	})
+
+	t.Run("Expiry capped at the subject token", func(t *testing.T) {
+		cfg := testJWTConfig()
+		cfg.TokenTTL = 30 * time.Second
+
+		shortLived, err := token.NewJWTService(cfg, nil)
+		assert.NoError(t, err)
+
+		subjectToken, err := shortLived.Issue(&models.User{
+			ID:        guid,
+			Ip:        "127.0.0.1",
+			SessionID: uuid.New(),
+			TokenID:   uuid.New(),
+		})
+		assert.NoError(t, err)
+
+		subject, err := jwtService.ParseAccessToken(context.Background(), subjectToken)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		status, result, _ := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {subjectToken},
+			"subject_token_type": {accessTokenType},
+		})
+		assert.Equal(t, http.StatusOK, status)
+		assert.LessOrEqual(t, result.ExpiresIn, int64(30))
+
+		exchanged, err := jwtService.ParseAccessToken(context.Background(), result.AccessToken)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.False(t, exchanged.ExpiresAt.After(subject.ExpiresAt), "the exchanged token outlives the subject token")
+	})
+
+	t.Run("Actor token", func(t *testing.T) {
+		actorToken, err := jwtService.IssueClient(gateway, uuid.New(), nil)
+		assert.NoError(t, err)
+
+		status, result, _ := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {accessToken},
+			"subject_token_type": {accessTokenType},
+			"actor_token":        {actorToken},
+			"actor_token_type":   {accessTokenType},
+		})
+		assert.Equal(t, http.StatusOK, status)
+
+		user, err := jwtService.ParseUser(context.Background(), result.AccessToken)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, gatewayID, user.Actor.Subject)
+	})
+
+	t.Run("Actor token of another client", func(t *testing.T) {
+		actorToken, err := jwtService.IssueClient(&models.Client{ID: publicID}, uuid.New(), nil)
+		assert.NoError(t, err)
+
+		status, _, oauthErr := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {accessToken},
+			"subject_token_type": {accessTokenType},
+			"actor_token":        {actorToken},
+			"actor_token_type":   {accessTokenType},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_request", oauthErr.Error)
+
+		status, _, oauthErr = exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {accessToken},
+			"subject_token_type": {accessTokenType},
+			"actor_token":        {login(uuid.New())},
+			"actor_token_type":   {accessTokenType},
+		})
+		assert.Equal(t, http.StatusBadRequest, status, "tokens of the cookie routes belong to no client")
+		assert.Equal(t, "invalid_request", oauthErr.Error)
+	})
+
+	t.Run("Audience not allowed", func(t *testing.T) {
+		status, _, oauthErr := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {accessToken},
+			"subject_token_type": {accessTokenType},
+			"audience":           {"https://billing.example.com"},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_target", oauthErr.Error)
+	})
+
+	t.Run("Logout revokes exchanged tokens", func(t *testing.T) {
+		subjectToken := login(uuid.New())
+
+		status, result, _ := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {subjectToken},
+			"subject_token_type": {accessTokenType},
+		})
+		assert.Equal(t, http.StatusOK, status)
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/token.revoke/", nil)
+		req.Header.Set("Authorization", "Bearer "+subjectToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		revoked, err := token.NewJWTService(testJWTConfig(), postgres.NewDenylistRepo(db))
+		assert.NoError(t, err)
+
+		_, err = revoked.ParseUser(context.Background(), result.AccessToken)
+		assert.ErrorIs(t, err, token.ErrTokenRevoked)
+	})
+
+	t.Run("Invalid subject token", func(t *testing.T) {
+		status, _, oauthErr := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {"not-a-token"},
+			"subject_token_type": {accessTokenType},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_grant", oauthErr.Error)
+	})
+
+	t.Run("Unsupported token type", func(t *testing.T) {
+		status, _, oauthErr := exchange(url.Values{
+			"client_id":          {gatewayID},
+			"client_secret":      {"gateway-secret"},
+			"subject_token":      {accessToken},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:id_token"},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_request", oauthErr.Error)
+	})
+
+	t.Run("Public client", func(t *testing.T) {
+		status, _, oauthErr := exchange(url.Values{
+			"client_id":          {publicID},
+			"subject_token":      {accessToken},
+			"subject_token_type": {accessTokenType},
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "unauthorized_client", oauthErr.Error)
+	})
+}
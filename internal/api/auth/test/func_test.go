@@ -3,9 +3,11 @@ package test
 import (
 	"auth/internal/api/auth"
 	"auth/internal/config"
+	"auth/internal/models"
 	"auth/internal/token"
 	"auth/internal/usecase"
 	"auth/internal/usecase/repo/postgres"
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/google/uuid"
@@ -14,13 +16,15 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 )
 
 const (
-	issuer            = "test-jwt"
+	issuer            = "https://auth.test"
 	secret            = "secret"
+	algorithm         = "HS512"
 	expiresIn         = 5 * time.Minute
 	sessionExpiresIn  = 24 * time.Hour
 	tokenLength       = 32
@@ -31,22 +35,52 @@ const (
 	GUID              = "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"
 )
 
-func testAuthHandler(db *sql.DB) *auth.AuthHandler {
-	jwtConfig := config.JWT{
+func testJWTConfig() *config.JWT {
+	return &config.JWT{
 		Issuer:             issuer,
 		Secret:             secret,
+		Algorithm:          algorithm,
 		TokenTTL:           expiresIn,
 		SessionTTL:         sessionExpiresIn,
 		RefreshTokenLength: tokenLength,
+		EphemeralKeys:      true,
 	}
+}
+
+// testJWTService parses tokens issued by the test handler without touching the denylist.
+func testJWTService(t *testing.T) *token.Service {
+	jwtSvc, err := token.NewJWTService(testJWTConfig(), nil)
+	assert.NoError(t, err)
+
+	return jwtSvc
+}
 
-	jwtSvc := token.NewJWTService(&jwtConfig)
+func testAuthHandler(t *testing.T, db *sql.DB) *auth.AuthHandler {
+	jwtSvc, err := token.NewJWTService(testJWTConfig(), postgres.NewDenylistRepo(db))
+	assert.NoError(t, err)
 
 	users := postgres.NewUserRepo(db)
 
 	userUseCase := usecase.NewUserUseCase(users)
 
-	authHandler := auth.NewAuthHandler(slog.Default(), jwtSvc, userUseCase, expiresIn, sessionExpiresIn, tokenLength)
+	sessions := postgres.NewSessionRepo(db)
+
+	sessionUseCase := usecase.NewSessionUseCase(sessions)
+
+	clients := postgres.NewClientRepo(db)
+
+	clientUseCase := usecase.NewClientUseCase(clients)
+
+	codes := postgres.NewCodeRepo(db)
+
+	codeUseCase := usecase.NewCodeUseCase(codes)
+
+	devices := postgres.NewDeviceRepo(db)
+
+	deviceUseCase := usecase.NewDeviceUseCase(devices)
+
+	authHandler := auth.NewAuthHandler(slog.Default(), jwtSvc, userUseCase, sessionUseCase, clientUseCase, codeUseCase,
+		deviceUseCase, expiresIn, sessionExpiresIn, tokenLength)
 
 	return authHandler
 }
@@ -62,7 +96,7 @@ func TestAuthHandler_GetFunctional(t *testing.T) {
 
 	defer db.Close()
 
-	authHandler := testAuthHandler(db)
+	authHandler := testAuthHandler(t, db)
 
 	server := httptest.NewServer(http.HandlerFunc(authHandler.Get))
 	defer server.Close()
@@ -105,6 +139,44 @@ func TestAuthHandler_GetFunctional(t *testing.T) {
 		assert.NotEmpty(t, accessToken)
 		assert.NotEmpty(t, refreshToken)
 	})
+
+	t.Run("Scopes", func(t *testing.T) {
+		guid := uuid.New().String()
+
+		users := postgres.NewUserRepo(db)
+		assert.NoError(t, users.Add(context.Background(), &models.User{ID: uuid.MustParse(guid)}))
+		assert.NoError(t, users.SetScopes(context.Background(), guid, []string{"orders:read", "orders:write"}))
+
+		get := func(scope string) *http.Response {
+			resp, err := http.Get(fmt.Sprintf("%s/token.get/?guid=%s&scope=%s", server.URL, guid,
+				url.QueryEscape(scope)))
+			assert.NoError(t, err)
+			resp.Body.Close()
+
+			return resp
+		}
+
+		resp := get("orders:read")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		user, err := testJWTService(t).ParseUser(context.Background(), cookieValue(resp.Cookies(), auth.AccessToken))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []string{"orders:read"}, user.Scopes)
+
+		resp = get("")
+		user, err = testJWTService(t).ParseUser(context.Background(), cookieValue(resp.Cookies(), auth.AccessToken))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []string{"orders:read", "orders:write"}, user.Scopes)
+
+		resp = get("admin")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
 }
 
 func TestAuthHandler_RefreshFunctional(t *testing.T) {
@@ -118,7 +190,7 @@ func TestAuthHandler_RefreshFunctional(t *testing.T) {
 
 	defer db.Close()
 
-	authHandler := testAuthHandler(db)
+	authHandler := testAuthHandler(t, db)
 
 	server := httptest.NewServer(http.HandlerFunc(authHandler.Refresh))
 	defer server.Close()
@@ -162,10 +234,13 @@ func TestAuthHandler_RefreshFunctional(t *testing.T) {
 
 		resp := rr.Result()
 
-		validRefreshToken := ""
+		validAccessToken, validRefreshToken := "", ""
 
 		cookies := resp.Cookies()
 		for _, cookie := range cookies {
+			if cookie.Name == auth.AccessToken {
+				validAccessToken = cookie.Value
+			}
 			if cookie.Name == auth.RefreshToken {
 				validRefreshToken = cookie.Value
 			}
@@ -174,6 +249,9 @@ func TestAuthHandler_RefreshFunctional(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.refresh/?guid=%s", server.URL, guid), nil)
 		req.Header.Set("X-Real-Ip", "127.0.0.1")
 
+		accessCookie := &http.Cookie{Name: auth.AccessToken, Value: validAccessToken}
+		req.AddCookie(accessCookie)
+
 		refreshCookie := &http.Cookie{Name: auth.RefreshToken, Value: validRefreshToken}
 		req.AddCookie(refreshCookie)
 
@@ -203,4 +281,142 @@ func TestAuthHandler_RefreshFunctional(t *testing.T) {
 	t.Run("Valid GUID and token", func(t *testing.T) {
 
 	})
+
+	t.Run("Reused token revokes family", func(t *testing.T) {
+		guid := uuid.New().String()
+
+		r, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.get/?guid=%s", server.URL, guid), nil)
+		r.Header.Set("X-Real-Ip", "127.0.0.1")
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(authHandler.Get).ServeHTTP(rr, r)
+
+		firstAccess := cookieValue(rr.Result().Cookies(), auth.AccessToken)
+		firstToken := cookieValue(rr.Result().Cookies(), auth.RefreshToken)
+
+		refresh := func(accessToken, refreshToken string) *http.Response {
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.refresh/?guid=%s", server.URL, guid), nil)
+			req.Header.Set("X-Real-Ip", "127.0.0.1")
+			req.AddCookie(&http.Cookie{Name: auth.AccessToken, Value: accessToken})
+			req.AddCookie(&http.Cookie{Name: auth.RefreshToken, Value: refreshToken})
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+
+			return resp
+		}
+
+		resp := refresh(firstAccess, firstToken)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		secondAccess := cookieValue(resp.Cookies(), auth.AccessToken)
+		secondToken := cookieValue(resp.Cookies(), auth.RefreshToken)
+		assert.NotEmpty(t, secondToken)
+
+		resp = refresh(firstAccess, firstToken)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = refresh(secondAccess, secondToken)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestAuthHandler_SessionsFunctional(t *testing.T) {
+	storagePath := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable", POSTGRES_USER,
+		POSTGRES_PASSWORD, ADDRESS, DB)
+
+	db, err := sql.Open("postgres", storagePath)
+	if err != nil {
+		assert.NoError(t, err)
+	}
+
+	defer db.Close()
+
+	authHandler := testAuthHandler(t, db)
+
+	r := http.NewServeMux()
+	r.HandleFunc("GET /token.get/", authHandler.Get)
+	r.HandleFunc("GET /token.refresh/", authHandler.Refresh)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	guid := uuid.New().String()
+
+	do := func(path, userAgent string, cookies []*http.Cookie) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s?guid=%s", server.URL, path, guid), nil)
+		req.Header.Set("X-Real-Ip", "127.0.0.1")
+		req.Header.Set("User-Agent", userAgent)
+
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+
+		resp.Body.Close()
+
+		return resp
+	}
+
+	laptop := do("/token.get/", "laptop", nil)
+	assert.Equal(t, http.StatusOK, laptop.StatusCode)
+
+	phone := do("/token.get/", "phone", nil)
+	assert.Equal(t, http.StatusOK, phone.StatusCode)
+
+	mixed := []*http.Cookie{
+		{Name: auth.AccessToken, Value: cookieValue(phone.Cookies(), auth.AccessToken)},
+		{Name: auth.RefreshToken, Value: cookieValue(laptop.Cookies(), auth.RefreshToken)},
+	}
+
+	resp := do("/token.refresh/", "laptop", mixed)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "tokens of different sessions must not be paired")
+
+	resp = do("/token.refresh/", "laptop", laptop.Cookies())
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "login on another device must keep the session")
+
+	resp = do("/token.refresh/", "phone", phone.Cookies())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	refreshed := resp
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == auth.AccessToken {
+			assert.WithinDuration(t, time.Now().Add(expiresIn), cookie.Expires, 5*time.Second,
+				"the access cookie expires with the token")
+		}
+	}
+
+	expired := []*http.Cookie{
+		{Name: auth.RefreshToken, Value: cookieValue(resp.Cookies(), auth.RefreshToken)},
+	}
+
+	resp = do("/token.refresh/", "phone", expired)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "a refresh token alone must not refresh")
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.refresh/?guid=%s", server.URL, guid), nil)
+	req.Header.Set("X-Real-Ip", "127.0.0.1")
+	req.Header.Set("User-Agent", "phone")
+	req.Header.Set("Authorization", "Bearer "+cookieValue(refreshed.Cookies(), auth.AccessToken))
+	req.AddCookie(expired[0])
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "the expired access cookie is presented in the header")
+}
+
+func cookieValue(cookies []*http.Cookie, name string) string {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+
+	return ""
 }
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"auth/internal/api/auth"
+	"auth/internal/models"
+	"auth/internal/usecase/repo/postgres"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// addTestClient registers an OAuth client with the given secret and scopes.
+func addTestClient(t *testing.T, db *sql.DB, secret string, scopes ...string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+
+	client := &models.Client{
+		ID:     "client-" + uuid.NewString(),
+		Secret: string(hash),
+		Name:   "test",
+		Scopes: scopes,
+	}
+
+	assert.NoError(t, postgres.NewClientRepo(db).Add(context.Background(), client))
+
+	return client.ID
+}
+
+func TestAuthHandler_IntrospectFunctional(t *testing.T) {
+	storagePath := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable", POSTGRES_USER,
+		POSTGRES_PASSWORD, ADDRESS, DB)
+
+	db, err := sql.Open("postgres", storagePath)
+	if err != nil {
+		assert.NoError(t, err)
+	}
+
+	defer db.Close()
+
+	authHandler := testAuthHandler(t, db)
+
+	r := http.NewServeMux()
+	r.HandleFunc("GET /token.get/", authHandler.Get)
+	r.HandleFunc("POST /token.revoke/", authHandler.Revoke)
+	r.HandleFunc("POST /oauth/introspect", authHandler.Introspect)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	clientID := addTestClient(t, db, "client-secret")
+
+	introspect := func(token, secret string) (*http.Response, auth.IntrospectResp) {
+		form := url.Values{"token": {token}}
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/oauth/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, secret)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var result auth.IntrospectResp
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+
+		return resp, result
+	}
+
+	guid := uuid.New().String()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.get/?guid=%s", server.URL, guid), nil)
+	req.Header.Set("X-Real-Ip", "127.0.0.1")
+
+	login, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	login.Body.Close()
+
+	accessToken := cookieValue(login.Cookies(), auth.AccessToken)
+
+	t.Run("Invalid client", func(t *testing.T) {
+		resp, _ := introspect(accessToken, "wrong")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+	})
+
+	t.Run("Encoded credentials", func(t *testing.T) {
+		const encodedSecret = "p@ss:w+rd/%"
+
+		encodedID := addTestClient(t, db, encodedSecret)
+
+		form := url.Values{"token": {accessToken}}
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/oauth/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(url.QueryEscape(encodedID), url.QueryEscape(encodedSecret))
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "basic credentials are form-urlencoded")
+	})
+
+	t.Run("Unknown client", func(t *testing.T) {
+		form := url.Values{"token": {accessToken}}
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/oauth/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("client-"+uuid.NewString(), "client-secret")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Active token", func(t *testing.T) {
+		resp, result := introspect(accessToken, "client-secret")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+		assert.True(t, result.Active)
+		assert.Equal(t, guid, result.Sub)
+		assert.Equal(t, issuer, result.Iss)
+		assert.Equal(t, "127.0.0.1", result.Ip)
+		assert.Greater(t, result.Exp, result.Iat)
+	})
+
+	t.Run("Malformed token", func(t *testing.T) {
+		resp, result := introspect("not-a-token", "client-secret")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.False(t, result.Active)
+		assert.Empty(t, result.Sub)
+	})
+
+	t.Run("Revoked token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/token.revoke/", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		_, result := introspect(accessToken, "client-secret")
+		assert.False(t, result.Active)
+	})
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"auth/internal/api/auth"
+	"auth/internal/config"
+	"auth/internal/models"
+	"auth/internal/token"
+	"auth/internal/usecase"
+	"auth/internal/usecase/repo/postgres"
+	"auth/pkg/jwt"
+	"auth/pkg/paseto"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthHandler_JWKS(t *testing.T) {
+	jwtSvc, err := token.NewJWTService(&config.JWT{
+		Issuer:        issuer,
+		Secret:        secret,
+		Algorithm:     string(jwt.ES256),
+		KeyID:         "test-key",
+		TokenTTL:      expiresIn,
+		EphemeralKeys: true,
+	}, nil)
+	assert.NoError(t, err)
+
+	userUseCase := usecase.NewUserUseCase(postgres.NewUserRepo(nil))
+	sessionUseCase := usecase.NewSessionUseCase(postgres.NewSessionRepo(nil))
+	clientUseCase := usecase.NewClientUseCase(postgres.NewClientRepo(nil))
+	codeUseCase := usecase.NewCodeUseCase(postgres.NewCodeRepo(nil))
+	deviceUseCase := usecase.NewDeviceUseCase(postgres.NewDeviceRepo(nil))
+	authHandler := auth.NewAuthHandler(slog.Default(), jwtSvc, userUseCase, sessionUseCase, clientUseCase, codeUseCase,
+		deviceUseCase, expiresIn, sessionExpiresIn, tokenLength)
+
+	server := httptest.NewServer(http.HandlerFunc(authHandler.JWKS))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "public, max-age=900", resp.Header.Get("Cache-Control"))
+
+	var set jwt.JSONWebKeySet
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&set))
+
+	if assert.Len(t, set.Keys, 1) {
+		assert.Equal(t, "test-key", set.Keys[0].KeyID)
+		assert.Equal(t, "ES256", set.Keys[0].Algorithm)
+	}
+}
+
+func TestAuthHandler_PasetoKeys(t *testing.T) {
+	jwtSvc, err := token.NewJWTService(&config.JWT{
+		Issuer:        issuer,
+		Secret:        secret,
+		Algorithm:     string(jwt.ES256),
+		TokenTTL:      expiresIn,
+		Format:        token.FormatPasetoPublic,
+		EphemeralKeys: true,
+	}, nil)
+	assert.NoError(t, err)
+
+	authHandler := auth.NewAuthHandler(slog.Default(), jwtSvc, nil, nil, nil, nil, nil, expiresIn, sessionExpiresIn,
+		tokenLength)
+
+	server := httptest.NewServer(http.HandlerFunc(authHandler.PasetoKeys))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/.well-known/paseto-keys.json")
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "public, max-age=900", resp.Header.Get("Cache-Control"))
+
+	var set paseto.KeySet
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&set))
+
+	if !assert.Len(t, set.Keys, 1) {
+		return
+	}
+
+	// a resource server verifies access tokens with the published key only
+	key, err := paseto.DecodePublicKey(set.Keys[0])
+	assert.NoError(t, err)
+
+	accessToken, err := jwtSvc.Issue(&models.User{ID: uuid.New(), SessionID: uuid.New(), TokenID: uuid.New()})
+	assert.NoError(t, err)
+
+	verifier := paseto.NewService(paseto.NewConfig().SetPublicKey(key).SetIssuer(issuer))
+
+	_, err = verifier.ParseTokenClaims(accessToken)
+	assert.NoError(t, err)
+
+	_, err = jwtSvc.ParseUser(context.Background(), accessToken)
+	assert.NoError(t, err)
+}
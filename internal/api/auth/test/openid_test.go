@@ -0,0 +1,61 @@
+package test
+
+import (
+	"auth/internal/api/auth"
+	"auth/internal/config"
+	"auth/internal/token"
+	"auth/internal/usecase"
+	"auth/internal/usecase/repo/postgres"
+	"auth/pkg/jwt"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAuthHandler_Discovery(t *testing.T) {
+	jwtSvc, err := token.NewJWTService(&config.JWT{
+		Issuer:        "https://auth.example.com/",
+		Algorithm:     string(jwt.RS256),
+		TokenTTL:      expiresIn,
+		EphemeralKeys: true,
+	}, nil)
+	assert.NoError(t, err)
+
+	authHandler := auth.NewAuthHandler(slog.Default(), jwtSvc, usecase.NewUserUseCase(postgres.NewUserRepo(nil)),
+		usecase.NewSessionUseCase(postgres.NewSessionRepo(nil)), usecase.NewClientUseCase(postgres.NewClientRepo(nil)),
+		usecase.NewCodeUseCase(postgres.NewCodeRepo(nil)), usecase.NewDeviceUseCase(postgres.NewDeviceRepo(nil)),
+		expiresIn, sessionExpiresIn, tokenLength)
+
+	server := httptest.NewServer(http.HandlerFunc(authHandler.Discovery))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/.well-known/openid-configuration")
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var discovery auth.DiscoveryResp
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&discovery))
+
+	assert.Equal(t, "https://auth.example.com/", discovery.Issuer)
+	assert.Equal(t, "https://auth.example.com/oauth/authorize", discovery.AuthorizationEndpoint)
+	assert.Equal(t, "https://auth.example.com/oauth/token", discovery.TokenEndpoint)
+	assert.Equal(t, "https://auth.example.com/userinfo", discovery.UserinfoEndpoint)
+	assert.Equal(t, "https://auth.example.com/.well-known/jwks.json", discovery.JWKSURI)
+	assert.Equal(t, []string{"RS256"}, discovery.IDTokenSigningAlgValuesSupported)
+	assert.Equal(t, []string{"S256"}, discovery.CodeChallengeMethodsSupported)
+
+	for _, endpoint := range []string{discovery.Issuer, discovery.AuthorizationEndpoint, discovery.TokenEndpoint,
+		discovery.UserinfoEndpoint, discovery.JWKSURI, discovery.RevocationEndpoint,
+		discovery.IntrospectionEndpoint, discovery.DeviceAuthorizationEndpoint} {
+		u, err := url.Parse(endpoint)
+		assert.NoError(t, err)
+		assert.True(t, u.IsAbs() && u.Host != "", "%q is not an absolute URL", endpoint)
+	}
+}
@@ -0,0 +1,207 @@
+package test
+
+import (
+	"auth/internal/api/auth"
+	"auth/internal/models"
+	"auth/internal/usecase/repo/postgres"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// addTestSession starts a session of a new user bound to the client, as the
+// authorization code flow would, and returns its refresh token.
+func addTestSession(t *testing.T, db *sql.DB, clientID string, scopes ...string) string {
+	ctx := context.Background()
+
+	user := &models.User{ID: uuid.New()}
+	assert.NoError(t, postgres.NewUserRepo(db).Add(ctx, user))
+
+	secret := make([]byte, tokenLength)
+	_, err := rand.Read(secret)
+	assert.NoError(t, err)
+
+	hash, err := bcrypt.GenerateFromPassword(secret, bcrypt.DefaultCost)
+	assert.NoError(t, err)
+
+	session := &models.Session{
+		ID:            uuid.New(),
+		UserID:        user.ID,
+		Token:         string(hash),
+		TokenID:       uuid.New(),
+		AccessTokenID: uuid.New(),
+		Ip:            "127.0.0.1",
+		ExpiresAt:     time.Now().Add(sessionExpiresIn),
+		ClientID:      clientID,
+		Scopes:        scopes,
+	}
+
+	assert.NoError(t, postgres.NewSessionRepo(db).Add(ctx, session))
+
+	return base64.URLEncoding.EncodeToString(append(session.TokenID[:], secret...))
+}
+
+func TestAuthHandler_OAuthRevokeFunctional(t *testing.T) {
+	storagePath := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable", POSTGRES_USER,
+		POSTGRES_PASSWORD, ADDRESS, DB)
+
+	db, err := sql.Open("postgres", storagePath)
+	if err != nil {
+		assert.NoError(t, err)
+	}
+
+	defer db.Close()
+
+	authHandler := testAuthHandler(t, db)
+
+	r := http.NewServeMux()
+	r.HandleFunc("GET /token.get/", authHandler.Get)
+	r.HandleFunc("GET /token.refresh/", authHandler.Refresh)
+	r.HandleFunc("POST /oauth/token", authHandler.Token)
+	r.HandleFunc("POST /oauth/revoke", authHandler.OAuthRevoke)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	clientID := addTestClient(t, db, "client-secret")
+
+	login := func(guid string) []*http.Cookie {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.get/?guid=%s", server.URL, guid), nil)
+		req.Header.Set("X-Real-Ip", "127.0.0.1")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.Cookies()
+	}
+
+	refresh := func(guid string, cookies []*http.Cookie) int {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.refresh/?guid=%s", server.URL, guid), nil)
+		req.Header.Set("X-Real-Ip", "127.0.0.1")
+
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	// refreshGrant exchanges the refresh token of a client bound session
+	refreshGrant := func(clientID, refreshToken string) (int, auth.TokenResp) {
+		resp, err := http.PostForm(server.URL+"/oauth/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+			"client_id":     {clientID},
+			"client_secret": {"client-secret"},
+		})
+		assert.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var result auth.TokenResp
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+
+		return resp.StatusCode, result
+	}
+
+	revoke := func(form url.Values) *http.Response {
+		form.Set("client_id", clientID)
+		form.Set("client_secret", "client-secret")
+
+		resp, err := http.PostForm(server.URL+"/oauth/revoke", form)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return resp
+	}
+
+	t.Run("Refresh token", func(t *testing.T) {
+		refreshToken := addTestSession(t, db, clientID)
+
+		resp := revoke(url.Values{
+			"token":           {refreshToken},
+			"token_type_hint": {"refresh_token"},
+		})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		status, _ := refreshGrant(clientID, refreshToken)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("Access token with wrong hint", func(t *testing.T) {
+		status, tokens := refreshGrant(clientID, addTestSession(t, db, clientID))
+		assert.Equal(t, http.StatusOK, status)
+
+		resp := revoke(url.Values{
+			"token":           {tokens.AccessToken},
+			"token_type_hint": {"refresh_token"},
+		})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		status, _ = refreshGrant(clientID, tokens.RefreshToken)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("Token of another client", func(t *testing.T) {
+		otherID := addTestClient(t, db, "client-secret")
+		refreshToken := addTestSession(t, db, otherID)
+
+		resp := revoke(url.Values{"token": {refreshToken}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "the client must not learn that the token exists")
+
+		status, _ := refreshGrant(otherID, refreshToken)
+		assert.Equal(t, http.StatusOK, status, "tokens of other clients are not revoked")
+	})
+
+	t.Run("Cookie session", func(t *testing.T) {
+		guid := uuid.New().String()
+		cookies := login(guid)
+
+		resp := revoke(url.Values{"token": {cookieValue(cookies, auth.RefreshToken)}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.Equal(t, http.StatusOK, refresh(guid, cookies), "sessions without a client are not revoked")
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		resp := revoke(url.Values{"token": {"unknown"}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Invalid client", func(t *testing.T) {
+		resp, err := http.PostForm(server.URL+"/oauth/revoke", url.Values{"token": {"unknown"}})
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/oauth/revoke", strings.NewReader(""))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, "client-secret")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
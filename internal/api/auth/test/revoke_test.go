@@ -0,0 +1,145 @@
+package test
+
+import (
+	"auth/internal/api/auth"
+	"auth/internal/usecase/repo/postgres"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthHandler_RevokeFunctional(t *testing.T) {
+	storagePath := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable", POSTGRES_USER,
+		POSTGRES_PASSWORD, ADDRESS, DB)
+
+	db, err := sql.Open("postgres", storagePath)
+	if err != nil {
+		assert.NoError(t, err)
+	}
+
+	defer db.Close()
+
+	authHandler := testAuthHandler(t, db)
+
+	r := http.NewServeMux()
+	r.HandleFunc("GET /token.get/", authHandler.Get)
+	r.HandleFunc("GET /token.refresh/", authHandler.Refresh)
+	r.HandleFunc("POST /token.revoke/", authHandler.Revoke)
+	r.HandleFunc("POST /sessions/{id}/revoke", authHandler.RevokeSession)
+	r.HandleFunc("POST /sessions/revoke-all", authHandler.RevokeAllSessions)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	do := func(method, path, guid string, cookies []*http.Cookie) *http.Response {
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s%s?guid=%s", server.URL, path, guid), nil)
+		req.Header.Set("X-Real-Ip", "127.0.0.1")
+
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("Revoke current session", func(t *testing.T) {
+		guid := uuid.New().String()
+
+		login := do(http.MethodGet, "/token.get/", guid, nil)
+		login.Body.Close()
+
+		resp := do(http.MethodPost, "/token.revoke/", guid, login.Cookies())
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		for _, cookie := range resp.Cookies() {
+			assert.Empty(t, cookie.Value)
+			assert.Negative(t, cookie.MaxAge)
+		}
+
+		resp = do(http.MethodGet, "/token.refresh/", guid, login.Cookies())
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		user, err := testJWTService(t).ParseUser(context.Background(), cookieValue(login.Cookies(), auth.AccessToken))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		revoked, err := postgres.NewDenylistRepo(db).Contains(context.Background(), user.TokenID.String())
+		assert.NoError(t, err)
+		assert.True(t, revoked, "the access token is denylisted until its expiry")
+	})
+
+	t.Run("Revoked access token is rejected", func(t *testing.T) {
+		guid := uuid.New().String()
+
+		login := do(http.MethodGet, "/token.get/", guid, nil)
+		login.Body.Close()
+
+		resp := do(http.MethodPost, "/token.revoke/", guid, login.Cookies())
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = do(http.MethodPost, "/sessions/revoke-all", guid, login.Cookies())
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "the denylist is checked on verification")
+	})
+
+	t.Run("Revoke other session", func(t *testing.T) {
+		guid := uuid.New().String()
+
+		laptop := do(http.MethodGet, "/token.get/", guid, nil)
+		laptop.Body.Close()
+
+		phone := do(http.MethodGet, "/token.get/", guid, nil)
+		phone.Body.Close()
+
+		phoneUser, err := testJWTService(t).ParseUser(context.Background(), cookieValue(phone.Cookies(), auth.AccessToken))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		resp := do(http.MethodPost, fmt.Sprintf("/sessions/%s/revoke", phoneUser.SessionID), guid, laptop.Cookies())
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var revoked auth.RevokeResp
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&revoked))
+		resp.Body.Close()
+		assert.Equal(t, []string{phoneUser.SessionID.String()}, revoked.Sessions)
+
+		resp = do(http.MethodGet, "/token.refresh/", guid, phone.Cookies())
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = do(http.MethodGet, "/token.refresh/", guid, laptop.Cookies())
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Revoke session of another user", func(t *testing.T) {
+		first := do(http.MethodGet, "/token.get/", uuid.New().String(), nil)
+		first.Body.Close()
+
+		second := do(http.MethodGet, "/token.get/", uuid.New().String(), nil)
+		second.Body.Close()
+
+		secondUser, err := testJWTService(t).ParseUser(context.Background(), cookieValue(second.Cookies(), auth.AccessToken))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		resp := do(http.MethodPost, fmt.Sprintf("/sessions/%s/revoke", secondUser.SessionID), "", first.Cookies())
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"auth/internal/api/auth"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAuthHandler_TokenFunctional(t *testing.T) {
+	storagePath := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable", POSTGRES_USER,
+		POSTGRES_PASSWORD, ADDRESS, DB)
+
+	db, err := sql.Open("postgres", storagePath)
+	if err != nil {
+		assert.NoError(t, err)
+	}
+
+	defer db.Close()
+
+	authHandler := testAuthHandler(t, db)
+
+	r := http.NewServeMux()
+	r.HandleFunc("GET /token.get/", authHandler.Get)
+	r.HandleFunc("POST /oauth/token", authHandler.Token)
+	r.HandleFunc("POST /oauth/introspect", authHandler.Introspect)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	clientID := addTestClient(t, db, "client-secret", "orders:read", "orders:write")
+
+	token := func(form url.Values) (*http.Response, auth.TokenResp, auth.OAuthErrorResp) {
+		form.Set("client_id", clientID)
+		form.Set("client_secret", "client-secret")
+
+		resp, err := http.PostForm(server.URL+"/oauth/token", form)
+		assert.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var (
+			result   auth.TokenResp
+			oauthErr auth.OAuthErrorResp
+		)
+
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		} else {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&oauthErr))
+		}
+
+		return resp, result, oauthErr
+	}
+
+	firstToken := addTestSession(t, db, clientID)
+
+	t.Run("Refresh token grant", func(t *testing.T) {
+		resp, result, _ := token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {firstToken}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+		assert.NotEmpty(t, result.AccessToken)
+		assert.Equal(t, "Bearer", result.TokenType)
+		assert.Equal(t, int64(expiresIn.Seconds()), result.ExpiresIn)
+		assert.NotEmpty(t, result.RefreshToken)
+		assert.NotEqual(t, firstToken, result.RefreshToken)
+
+		resp, _, _ = token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {result.RefreshToken}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Reused refresh token", func(t *testing.T) {
+		resp, _, oauthErr := token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {firstToken}})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", oauthErr.Error)
+	})
+
+	t.Run("Refresh token of another client", func(t *testing.T) {
+		otherID := addTestClient(t, db, "client-secret")
+		otherToken := addTestSession(t, db, otherID)
+
+		resp, _, oauthErr := token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {otherToken}})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", oauthErr.Error)
+	})
+
+	t.Run("Refresh token of a cookie session", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/token.get/?guid=%s", server.URL, uuid.New()), nil)
+		req.Header.Set("X-Real-Ip", "127.0.0.1")
+
+		login, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		login.Body.Close()
+
+		resp, _, oauthErr := token(url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {cookieValue(login.Cookies(), auth.RefreshToken)},
+		})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", oauthErr.Error)
+	})
+
+	t.Run("Client credentials grant", func(t *testing.T) {
+		resp, result, _ := token(url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:read"}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.NotEmpty(t, result.AccessToken)
+		assert.Empty(t, result.RefreshToken)
+		assert.Equal(t, "orders:read", result.Scope)
+
+		resp, err := http.PostForm(server.URL+"/oauth/introspect", url.Values{
+			"token":         {result.AccessToken},
+			"client_id":     {clientID},
+			"client_secret": {"client-secret"},
+		})
+		assert.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var introspection auth.IntrospectResp
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&introspection))
+
+		assert.True(t, introspection.Active)
+		assert.Equal(t, clientID, introspection.Sub)
+		assert.Equal(t, clientID, introspection.ClientID)
+		assert.Equal(t, "orders:read", introspection.Scope)
+	})
+
+	t.Run("Client credentials with all scopes", func(t *testing.T) {
+		resp, result, _ := token(url.Values{"grant_type": {"client_credentials"}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "orders:read orders:write", result.Scope)
+	})
+
+	t.Run("Client credentials with unknown scope", func(t *testing.T) {
+		resp, _, oauthErr := token(url.Values{"grant_type": {"client_credentials"}, "scope": {"admin"}})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_scope", oauthErr.Error)
+	})
+
+	t.Run("Unsupported grant type", func(t *testing.T) {
+		resp, _, oauthErr := token(url.Values{"grant_type": {"password"}})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "unsupported_grant_type", oauthErr.Error)
+	})
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"auth/internal/models"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TokenResp is the RFC 6749 section 5.1 access token response.
+type TokenResp struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+}
+
+// Token is the OAuth 2.0 token endpoint. Unlike the cookie based routes it
+// returns the tokens in the response body for OAuth client libraries.
+func (a *AuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	client, err := a.authenticateClient(r)
+	if errors.Is(err, ErrInvalidClient) {
+		a.log.Error("invalid client credentials")
+		a.writeOAuthError(w, errInvalidClient, "client authentication failed", http.StatusUnauthorized)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to authenticate client", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	switch grantType := r.PostFormValue("grant_type"); grantType {
+	case grantRefreshToken:
+		a.refreshTokenGrant(w, r, client)
+	case grantClientCredentials:
+		a.clientCredentialsGrant(w, r, client)
+	case grantAuthorizationCode:
+		a.authorizationCodeGrant(w, r, client)
+	case grantDeviceCode:
+		a.deviceCodeGrant(w, r, client)
+	case grantTokenExchange:
+		a.tokenExchangeGrant(w, r, client)
+	case "":
+		a.writeOAuthError(w, errInvalidRequest, "grant_type is required", http.StatusBadRequest)
+	default:
+		a.log.Error("unsupported grant type", slog.Any("client", client.ID), slog.Any("grant_type", grantType))
+		a.writeOAuthError(w, errUnsupported, "", http.StatusBadRequest)
+	}
+}
+
+// refreshTokenGrant exchanges the refresh token for a new pair, the same way as
+// Refresh does for cookies. Only the client the session was started by may
+// exchange its token. A replayed token revokes its session.
+func (a *AuthHandler) refreshTokenGrant(w http.ResponseWriter, r *http.Request, client *models.Client) {
+	encoded := r.PostFormValue("refresh_token")
+	if encoded == "" {
+		a.writeOAuthError(w, errInvalidRequest, "refresh_token is required", http.StatusBadRequest)
+
+		return
+	}
+
+	tokenID, refreshToken, err := decodeRefreshToken(encoded)
+	if err != nil {
+		a.log.Error("invalid refresh token", slog.Any("client", client.ID), slog.Any("error", err))
+		a.writeOAuthError(w, errInvalidGrant, "invalid refresh token", http.StatusBadRequest)
+
+		return
+	}
+
+	session, err := a.session.GetByTokenID(context.Background(), tokenID.String())
+	if errors.Is(err, models.ErrNotFound) {
+		a.detectReuse("", tokenID.String(), refreshToken)
+		a.writeOAuthError(w, errInvalidGrant, "invalid refresh token", http.StatusBadRequest)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to get session by token", slog.Any("error", err))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(session.Token), refreshToken)
+	if err != nil {
+		a.log.Error("invalid refresh token", slog.Any("client", client.ID), slog.Any("error", err))
+		a.writeOAuthError(w, errInvalidGrant, "invalid refresh token", http.StatusBadRequest)
+
+		return
+	}
+
+	// sessions of the cookie routes have no client and cannot be refreshed here
+	if session.ClientID != client.ID {
+		a.log.Error("refresh token issued to another client", slog.Any("client", client.ID),
+			slog.Any("session", session.ID))
+		a.writeOAuthError(w, errInvalidGrant, "invalid refresh token", http.StatusBadRequest)
+
+		return
+	}
+
+	if session.Revoked || time.Now().After(session.ExpiresAt) {
+		a.log.Error("session expired or revoked", slog.Any("GUID", session.UserID), slog.Any("session", session.ID))
+		a.writeOAuthError(w, errInvalidGrant, "refresh token expired or revoked", http.StatusBadRequest)
+
+		return
+	}
+
+	user, newRefreshToken, err := a.rotateSession(session, realIP(r), r.UserAgent())
+	if errors.Is(err, ErrRefreshTokenReused) {
+		a.writeOAuthError(w, errInvalidGrant, "invalid refresh token", http.StatusBadRequest)
+
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to rotate refresh token", slog.Any("error", err.Error()))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	a.writeTokens(w, user, newRefreshToken, "")
+
+	a.log.Info("successful refresh tokens to client", slog.Any("client", client.ID), slog.Any("GUID", user.ID))
+}
+
+// clientCredentialsGrant issues an access token to the client itself. No refresh
+// token is returned, the client authenticates again when the token expires.
+func (a *AuthHandler) clientCredentialsGrant(w http.ResponseWriter, r *http.Request, client *models.Client) {
+	if client.Public {
+		a.log.Error("public client requested client credentials", slog.Any("client", client.ID))
+		a.writeOAuthError(w, errUnauthorized, "public clients cannot use client_credentials", http.StatusBadRequest)
+
+		return
+	}
+
+	scopes, ok := grantScopes(client, r.PostFormValue("scope"))
+	if !ok {
+		a.log.Error("scope is not allowed for client", slog.Any("client", client.ID),
+			slog.Any("scope", r.PostFormValue("scope")))
+		a.writeOAuthError(w, errInvalidScope, "requested scope is not allowed", http.StatusBadRequest)
+
+		return
+	}
+
+	accessToken, err := a.jwt.IssueClient(client, uuid.New(), scopes)
+	if err != nil {
+		a.log.Error("failed to generate access token", slog.Any("error", err.Error()))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	expiresIn := a.tokenTTL
+	if client.TokenTTL > 0 {
+		expiresIn = client.TokenTTL
+	}
+
+	a.writeOAuth(w, TokenResp{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(expiresIn.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	})
+
+	a.log.Info("give tokens to client", slog.Any("client", client.ID))
+}
+
+// writeTokens issues the access token for the user and writes it with the refresh
+// token of its session and the ID token, if there is one.
+func (a *AuthHandler) writeTokens(w http.ResponseWriter, user *models.User, refreshToken, idToken string) {
+	accessToken, err := a.jwt.Issue(user)
+	if err != nil {
+		a.log.Error("failed to generate access token", slog.Any("error", err.Error()))
+		a.writeOAuthError(w, errServerError, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	a.writeOAuth(w, TokenResp{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(a.tokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(user.Scopes, " "),
+		IDToken:      idToken,
+	})
+}
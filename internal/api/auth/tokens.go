@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"auth/internal/models"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"time"
+)
+
+// newRefreshToken generates the next refresh token of the session and stores
+// its ID and bcrypt hash in the session, the returned value goes to the cookie.
+// The token is the token ID followed by random bytes and carries no user data.
+func (a *AuthHandler) newRefreshToken(session *models.Session) (string, error) {
+	secret, err := generateRefreshToken(a.tokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword(secret, bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	session.TokenID = uuid.New()
+	session.Token = string(hash)
+	session.ExpiresAt = time.Now().Add(a.sessionTTL)
+
+	return encodeRefreshToken(session.TokenID, secret), nil
+}
+
+// pairTokens links the access token about to be issued to the session: the token
+// carries the session ID as `sid` and the session remembers the token's `jti`.
+func pairTokens(user *models.User, session *models.Session) {
+	user.SessionID = session.ID
+	user.TokenID = uuid.New()
+	session.AccessTokenID = user.TokenID
+}
+
+// setTokens issues an access token for the user and puts both tokens into cookies.
+func (a *AuthHandler) setTokens(w http.ResponseWriter, user *models.User, refreshToken string) error {
+	token, err := a.jwt.Issue(user)
+	if err != nil {
+		return err
+	}
+
+	accessCookie := generateCookie(
+		AccessToken,
+		token,
+		"/",
+		"",
+		a.tokenTTL,
+		false,
+		false,
+		http.SameSiteStrictMode,
+	)
+
+	refreshCookie := generateCookie(
+		RefreshToken,
+		refreshToken,
+		"/",
+		"",
+		a.sessionTTL,
+		false,
+		true,
+		http.SameSiteStrictMode,
+	)
+
+	http.SetCookie(w, &accessCookie)
+	http.SetCookie(w, &refreshCookie)
+
+	return nil
+}
+
+// clearTokens removes both token cookies from the client.
+func (a *AuthHandler) clearTokens(w http.ResponseWriter) {
+	for _, name := range []string{AccessToken, RefreshToken} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == RefreshToken,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
+
+// generateRefreshToken returns an opaque token of the given length read from crypto/rand.
+func generateRefreshToken(length int) ([]byte, error) {
+	if length <= 0 || length > maxRefreshTokenLength {
+		return nil, ErrInvalidTokenLength
+	}
+
+	token := make([]byte, length)
+
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func encodeRefreshToken(ID uuid.UUID, secret []byte) string {
+	return base64.URLEncoding.EncodeToString(append(ID[:], secret...))
+}
+
+func decodeRefreshToken(value string) (uuid.UUID, []byte, error) {
+	raw, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	if len(raw) <= len(uuid.UUID{}) {
+		return uuid.Nil, nil, ErrMalformedRefreshToken
+	}
+
+	ID, err := uuid.FromBytes(raw[:len(uuid.UUID{})])
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	return ID, raw[len(uuid.UUID{}):], nil
+}
@@ -18,6 +18,8 @@ import (
 	"time"
 )
 
+const denylistEvictInterval = time.Minute
+
 func Run() {
 	logger := setupLogger()
 
@@ -32,7 +34,7 @@ func Run() {
 
 	db, err := sql.Open("postgres", storagePath)
 	if err != nil {
-		logger.Error("failed to connect db: ", err)
+		logger.Error("failed to connect db", slog.Any("error", err.Error()))
 	}
 
 	defer db.Close()
@@ -44,18 +46,65 @@ func Run() {
 		logger.Info("successful connect to db")
 	}
 
-	jwtSrv := token.NewJWTService(&cfg.JWT)
+	denylist, err := newDenylist(cfg.JWT.Denylist, db)
+	if err != nil {
+		logger.Error("failed to create denylist", slog.Any("error", err.Error()))
+		os.Exit(1)
+	}
+
+	if cfg.JWT.EphemeralKeys {
+		logger.Warn("ephemeral signing keys are enabled, tokens are invalidated on restart and not shared " +
+			"between replicas, do not use it in production")
+	}
+
+	jwtSrv, err := token.NewJWTService(&cfg.JWT, denylist)
+	if err != nil {
+		logger.Error("failed to create jwt service", slog.Any("error", err.Error()))
+		os.Exit(1)
+	}
 
 	users := postgres.NewUserRepo(db)
 
 	userUseCase := usecase.NewUserUseCase(users)
 
-	authHandler := auth.NewAuthHandler(logger, jwtSrv, userUseCase, cfg.JWT.TokenTTL, cfg.JWT.SessionTTL, cfg.JWT.RefreshTokenLength)
+	sessions := postgres.NewSessionRepo(db)
+
+	sessionUseCase := usecase.NewSessionUseCase(sessions)
+
+	clients := postgres.NewClientRepo(db)
+
+	clientUseCase := usecase.NewClientUseCase(clients)
+
+	codes := postgres.NewCodeRepo(db)
+
+	codeUseCase := usecase.NewCodeUseCase(codes)
+
+	devices := postgres.NewDeviceRepo(db)
+
+	deviceUseCase := usecase.NewDeviceUseCase(devices)
+
+	authHandler := auth.NewAuthHandler(logger, jwtSrv, userUseCase, sessionUseCase, clientUseCase, codeUseCase,
+		deviceUseCase, cfg.JWT.TokenTTL, cfg.JWT.SessionTTL, cfg.JWT.RefreshTokenLength)
 
 	r := http.NewServeMux()
 
 	r.HandleFunc("GET /token.get/", authHandler.Get)
 	r.HandleFunc("GET /token.refresh/", authHandler.Refresh)
+	r.HandleFunc("POST /token.revoke/", authHandler.Revoke)
+	r.HandleFunc("POST /sessions/{id}/revoke", authHandler.RevokeSession)
+	r.HandleFunc("POST /sessions/revoke-all", authHandler.RevokeAllSessions)
+	r.HandleFunc("GET /.well-known/jwks.json", authHandler.JWKS)
+	r.HandleFunc("GET /.well-known/paseto-keys.json", authHandler.PasetoKeys)
+	r.HandleFunc("GET /.well-known/openid-configuration", authHandler.Discovery)
+	r.HandleFunc("GET /userinfo", authHandler.UserInfo)
+	r.HandleFunc("POST /userinfo", authHandler.UserInfo)
+	r.HandleFunc("GET /oauth/authorize", authHandler.Authorize)
+	r.HandleFunc("POST /oauth/token", authHandler.Token)
+	r.HandleFunc("POST /oauth/device_authorization", authHandler.DeviceAuthorization)
+	r.HandleFunc("GET /device", authHandler.Device)
+	r.HandleFunc("POST /device", authHandler.ApproveDevice)
+	r.HandleFunc("POST /oauth/introspect", authHandler.Introspect)
+	r.HandleFunc("POST /oauth/revoke", authHandler.OAuthRevoke)
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
@@ -81,7 +130,7 @@ func Run() {
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Error("failed to stop server: ", err)
+		logger.Error("failed to stop server", slog.Any("error", err.Error()))
 
 		return
 	}
@@ -89,6 +138,19 @@ func Run() {
 	logger.Info("server stopped")
 }
 
+// newDenylist selects where revoked access tokens are kept. The in-memory
+// denylist is not shared between instances of the service.
+func newDenylist(backend string, db *sql.DB) (token.Denylist, error) {
+	switch backend {
+	case "memory":
+		return token.NewMemoryDenylist(denylistEvictInterval), nil
+	case "postgres", "":
+		return postgres.NewDenylistRepo(db), nil
+	default:
+		return nil, fmt.Errorf("unknown denylist backend %q", backend)
+	}
+}
+
 func setupLogger() *slog.Logger {
 	var log *slog.Logger
 
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -28,13 +31,66 @@ type (
 
 	JWT struct {
 		Issuer             string        `yaml:"issuer" env-required:"true"`
+		Audience           []string      `env:"JWT_AUDIENCE" yaml:"audience" env-separator:","`
 		Secret             string        `env:"SECRET" env-required:"true"`
+		Algorithm          string        `env:"JWT_ALGORITHM" yaml:"algorithm" env-default:"HS512"`
+		PrivateKeyPath     string        `env:"JWT_PRIVATE_KEY_PATH" yaml:"private_key_path"`
+		KeyID              string        `env:"JWT_KEY_ID" yaml:"key_id"`
+		EphemeralKeys      bool          `env:"JWT_EPHEMERAL_KEYS" yaml:"ephemeral_keys"`
+		IDToken            IDToken       `yaml:"id_token"`
+		Encryption         JWTEncryption `yaml:"encryption"`
+		Format             string        `env:"TOKEN_FORMAT" yaml:"format" env-default:"jwt"`
+		Paseto             Paseto        `yaml:"paseto"`
+		Keys               []JWTKey      `yaml:"keys"`
 		TokenTTL           time.Duration `env:"TOKEN_TTL" yaml:"token_ttl"`
 		SessionTTL         time.Duration `env:"SESSION_TTL" yaml:"session_ttl"`
-		RefreshTokenLength int           `yaml:"refresh_token_length"`
+		Leeway             time.Duration `env:"JWT_LEEWAY" yaml:"leeway"`
+		MaxAge             time.Duration `env:"JWT_MAX_AGE" yaml:"max_age"`
+		NotBefore          bool          `env:"JWT_NOT_BEFORE" yaml:"not_before"`
+		NotBeforeDelay     time.Duration `env:"JWT_NOT_BEFORE_DELAY" yaml:"not_before_delay"`
+		RefreshTokenLength int           `yaml:"refresh_token_length" env-default:"32"`
+		Denylist           string        `env:"JWT_DENYLIST" yaml:"denylist" env-default:"postgres"`
+	}
+
+	// IDToken is the asymmetric key ID tokens are signed with when access tokens
+	// are signed with a secret, clients verify ID tokens with the published JWKS.
+	IDToken struct {
+		Algorithm      string `env:"ID_TOKEN_ALGORITHM" yaml:"algorithm" env-default:"RS256"`
+		KeyID          string `env:"ID_TOKEN_KEY_ID" yaml:"key_id"`
+		PrivateKeyPath string `env:"ID_TOKEN_PRIVATE_KEY_PATH" yaml:"private_key_path"`
+	}
+
+	// JWTEncryption encrypts issued access tokens, Algorithm is dir, RSA-OAEP or RSA-OAEP-256.
+	// Direct encryption takes a base64 encoded 32 byte Key, RSA a private key PEM file.
+	JWTEncryption struct {
+		Algorithm      string `env:"JWT_ENCRYPTION_ALGORITHM" yaml:"algorithm"`
+		KeyID          string `env:"JWT_ENCRYPTION_KEY_ID" yaml:"key_id"`
+		Key            string `env:"JWT_ENCRYPTION_KEY"`
+		PrivateKeyPath string `env:"JWT_ENCRYPTION_PRIVATE_KEY_PATH" yaml:"private_key_path"`
+	}
+
+	// Paseto holds the key of PASETO access tokens, a base64 encoded 32 byte
+	// LocalKey for v4.local or an Ed25519 private key PEM file for v4.public.
+	Paseto struct {
+		LocalKey       string `env:"PASETO_LOCAL_KEY"`
+		PrivateKeyPath string `env:"PASETO_PRIVATE_KEY_PATH" yaml:"private_key_path"`
+	}
+
+	JWTKey struct {
+		ID             string    `yaml:"id"`
+		Algorithm      string    `yaml:"algorithm"`
+		Secret         string    `yaml:"secret"`
+		PrivateKeyPath string    `yaml:"private_key_path"`
+		PublicKeyPath  string    `yaml:"public_key_path"`
+		State          string    `yaml:"state"`
+		ActivatesAt    time.Time `yaml:"activates_at"`
+		RetiresAt      time.Time `yaml:"retires_at"`
 	}
 )
 
+// ErrInvalidIssuer is returned for an issuer that is not an absolute URL.
+var ErrInvalidIssuer = errors.New("jwt issuer must be an absolute URL")
+
 func Read(yamlPath string) (*Config, error) {
 	var cfg Config
 
@@ -43,5 +99,20 @@ func Read(yamlPath string) (*Config, error) {
 		return nil, err
 	}
 
+	if err = cfg.JWT.validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
+
+// validate checks the issuer, discovery and the device flow publish the
+// endpoints of the service relative to it.
+func (j *JWT) validate() error {
+	issuer, err := url.Parse(j.Issuer)
+	if err != nil || !issuer.IsAbs() || issuer.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidIssuer, j.Issuer)
+	}
+
+	return nil
+}
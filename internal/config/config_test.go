@@ -0,0 +1,93 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	return path
+}
+
+func TestRead_JWT(t *testing.T) {
+	t.Setenv("SECRET", "secret")
+
+	cfg, err := Read(writeConfig(t, `
+jwt:
+  issuer: https://auth.example.com
+  audience:
+    - https://orders.example.com
+    - https://billing.example.com
+  leeway: 5s
+  max_age: 24h
+  not_before: true
+  not_before_delay: 1s
+`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "https://auth.example.com", cfg.JWT.Issuer)
+	assert.Equal(t, []string{"https://orders.example.com", "https://billing.example.com"}, cfg.JWT.Audience)
+	assert.Equal(t, 5*time.Second, cfg.JWT.Leeway)
+	assert.Equal(t, 24*time.Hour, cfg.JWT.MaxAge)
+	assert.True(t, cfg.JWT.NotBefore)
+	assert.Equal(t, time.Second, cfg.JWT.NotBeforeDelay)
+	assert.Equal(t, "HS512", cfg.JWT.Algorithm)
+	assert.Equal(t, "secret", cfg.JWT.Secret)
+}
+
+func TestRead_JWTEnv(t *testing.T) {
+	t.Setenv("SECRET", "secret")
+	t.Setenv("JWT_AUDIENCE", "https://orders.example.com,https://billing.example.com")
+	t.Setenv("JWT_LEEWAY", "10s")
+	t.Setenv("JWT_MAX_AGE", "1h")
+	t.Setenv("JWT_NOT_BEFORE", "true")
+
+	cfg, err := Read(writeConfig(t, `
+jwt:
+  issuer: https://auth.example.com
+  leeway: 5s
+`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"https://orders.example.com", "https://billing.example.com"}, cfg.JWT.Audience)
+	assert.Equal(t, 10*time.Second, cfg.JWT.Leeway, "the environment overrides the file")
+	assert.Equal(t, time.Hour, cfg.JWT.MaxAge)
+	assert.True(t, cfg.JWT.NotBefore)
+	assert.Zero(t, cfg.JWT.NotBeforeDelay)
+}
+
+func TestRead_MissingSecret(t *testing.T) {
+	t.Setenv("SECRET", "")
+	os.Unsetenv("SECRET")
+
+	_, err := Read(writeConfig(t, `
+jwt:
+  issuer: https://auth.example.com
+`))
+	assert.Error(t, err)
+}
+
+func TestRead_Shipped(t *testing.T) {
+	t.Setenv("SECRET", "secret")
+
+	cfg, err := Read(filepath.Join("..", "..", "config", "config.yaml"))
+	assert.NoError(t, err)
+
+	assert.False(t, cfg.JWT.EphemeralKeys, "generated keys differ between restarts and replicas")
+	assert.Equal(t, "http://localhost:8080", cfg.JWT.Issuer)
+}
+
+func TestRead_InvalidIssuer(t *testing.T) {
+	t.Setenv("SECRET", "secret")
+
+	for _, issuer := range []string{"auth-svc", "/auth", "https://"} {
+		_, err := Read(writeConfig(t, "jwt:\n  issuer: "+issuer+"\n"))
+		assert.ErrorIs(t, err, ErrInvalidIssuer, issuer)
+	}
+}
@@ -0,0 +1,3 @@
+ALTER TABLE authorization_codes
+    DROP COLUMN IF EXISTS nonce,
+    DROP COLUMN IF EXISTS auth_time;
\ No newline at end of file
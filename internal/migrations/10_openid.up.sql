@@ -0,0 +1,3 @@
+ALTER TABLE authorization_codes
+    ADD COLUMN IF NOT EXISTS nonce TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS auth_time TIMESTAMPTZ NOT NULL DEFAULT now();
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS device_codes(
+    device_code TEXT PRIMARY KEY NOT NULL,
+    user_code TEXT NOT NULL UNIQUE,
+    client_id TEXT NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    user_id UUID REFERENCES users (id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    poll_interval INTEGER NOT NULL,
+    last_polled_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS device_codes_expires_at_idx ON device_codes (expires_at);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
\ No newline at end of file
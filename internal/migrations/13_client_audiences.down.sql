@@ -0,0 +1,2 @@
+ALTER TABLE clients
+    DROP COLUMN IF EXISTS audiences;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE clients
+    ADD COLUMN IF NOT EXISTS audiences TEXT[] NOT NULL DEFAULT '{}';
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS expires_at;
\ No newline at end of file
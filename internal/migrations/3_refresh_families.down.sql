@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS consumed_refresh_tokens;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS token_id,
+    DROP COLUMN IF EXISTS family_id,
+    DROP COLUMN IF EXISTS revoked_at;
\ No newline at end of file
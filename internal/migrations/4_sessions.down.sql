@@ -0,0 +1,12 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS token TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS token_id UUID,
+    ADD COLUMN IF NOT EXISTS family_id UUID,
+    ADD COLUMN IF NOT EXISTS ip TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+
+ALTER INDEX IF EXISTS consumed_refresh_tokens_session_id_idx RENAME TO consumed_refresh_tokens_family_id_idx;
+ALTER TABLE consumed_refresh_tokens RENAME COLUMN session_id TO family_id;
+
+DROP TABLE IF EXISTS sessions;
\ No newline at end of file
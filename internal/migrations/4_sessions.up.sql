@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS sessions(
+    id UUID PRIMARY KEY NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token TEXT NOT NULL,
+    token_id UUID NOT NULL UNIQUE,
+    ip TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+
+INSERT INTO sessions (id, user_id, token, token_id, ip, expires_at, revoked_at)
+SELECT family_id, id, token, token_id, ip, expires_at, revoked_at FROM users
+WHERE family_id IS NOT NULL AND token_id IS NOT NULL;
+
+ALTER TABLE consumed_refresh_tokens RENAME COLUMN family_id TO session_id;
+ALTER INDEX IF EXISTS consumed_refresh_tokens_family_id_idx RENAME TO consumed_refresh_tokens_session_id_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS token,
+    DROP COLUMN IF EXISTS token_id,
+    DROP COLUMN IF EXISTS family_id,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS revoked_at;
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    id UUID PRIMARY KEY NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS clients(
+    id TEXT PRIMARY KEY NOT NULL,
+    secret TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
\ No newline at end of file
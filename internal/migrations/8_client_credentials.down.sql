@@ -0,0 +1,3 @@
+ALTER TABLE clients
+    DROP COLUMN IF EXISTS scopes,
+    DROP COLUMN IF EXISTS token_ttl;
\ No newline at end of file
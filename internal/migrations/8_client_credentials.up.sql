@@ -0,0 +1,3 @@
+ALTER TABLE clients
+    ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS token_ttl INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
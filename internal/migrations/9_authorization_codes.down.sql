@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS authorization_codes;
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS client_id,
+    DROP COLUMN IF EXISTS scopes;
+
+ALTER TABLE clients
+    DROP COLUMN IF EXISTS redirect_uris,
+    DROP COLUMN IF EXISTS public;
\ No newline at end of file
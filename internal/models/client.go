@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Client is a service that calls the OAuth endpoints, Secret holds the bcrypt hash
+// of its secret. Scopes limit what its tokens may be granted and TokenTTL, when
+// set, overrides the default lifetime of access tokens issued to it. Audiences
+// are the services it may request tokens for with token exchange. Public
+// clients, such as SPAs and mobile apps, have no secret and must use PKCE.
+type Client struct {
+	ID           string
+	Secret       string
+	Name         string
+	Scopes       []string
+	TokenTTL     time.Duration
+	RedirectURIs []string
+	Audiences    []string
+	Public       bool
+}
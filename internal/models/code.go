@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// AuthorizationCode is issued by the authorize endpoint and exchanged once for
+// tokens. Code holds the SHA-256 hash of the value sent to the client and
+// SessionID is the session the tokens will belong to, so it can be revoked
+// when the code is replayed. RedirectURI is the one sent to the authorize
+// endpoint, it is empty when the registered URI was used by default and then
+// need not be repeated on exchange. Nonce and AuthTime are put into the ID token.
+type AuthorizationCode struct {
+	Code          string
+	ClientID      string
+	UserID        uuid.UUID
+	SessionID     uuid.UUID
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	Nonce         string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	Used          bool
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type DeviceCodeStatus string
+
+const (
+	DevicePending  DeviceCodeStatus = "pending"
+	DeviceApproved DeviceCodeStatus = "approved"
+	DeviceDenied   DeviceCodeStatus = "denied"
+	DeviceUsed     DeviceCodeStatus = "used"
+)
+
+// DeviceCode is a pending device authorization. Code holds the SHA-256 hash of
+// the device code the device polls with, UserCode is what the user enters on
+// the verification page. UserID is set once the user approves it.
+type DeviceCode struct {
+	Code         string
+	UserCode     string
+	ClientID     string
+	Scopes       []string
+	UserID       uuid.UUID
+	Status       DeviceCodeStatus
+	Interval     time.Duration
+	LastPolledAt time.Time
+	ExpiresAt    time.Time
+}
@@ -0,0 +1,5 @@
+package models
+
+import "errors"
+
+var ErrNotFound = errors.New("not found")
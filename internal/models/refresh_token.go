@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// RefreshToken is a refresh token that was already exchanged for a new pair,
+// it is remembered to detect a replay of the token.
+type RefreshToken struct {
+	ID        uuid.UUID
+	SessionID uuid.UUID
+	UserID    uuid.UUID
+	Token     string
+	ExpiresAt time.Time
+}
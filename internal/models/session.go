@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Session is a login of the user on one device. Token holds the bcrypt hash
+// of the current refresh token, all refresh tokens rotated within a session
+// form one family. AccessTokenID is the `jti` of the access token issued
+// together with the current refresh token. ClientID and Scopes are set for
+// sessions started through the authorization code flow.
+type Session struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	Token         string
+	TokenID       uuid.UUID
+	AccessTokenID uuid.UUID
+	Ip            string
+	UserAgent     string
+	CreatedAt     time.Time
+	LastUsedAt    time.Time
+	ExpiresAt     time.Time
+	Revoked       bool
+	ClientID      string
+	Scopes        []string
+}
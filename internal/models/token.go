@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// AccessToken is a verified access token with the registered claims needed to
+// describe it to other services. User is nil for tokens issued to a client
+// itself, their Subject is the client ID.
+type AccessToken struct {
+	Subject   string
+	TokenID   uuid.UUID
+	User      *User
+	Issuer    string
+	ClientID  string
+	Scopes    []string
+	Audience  []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
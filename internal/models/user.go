@@ -2,8 +2,25 @@ package models
 
 import "github.com/google/uuid"
 
+// User is the subject of an access token. SessionID and TokenID are the `sid`
+// and `jti` claims that pair the access token with the refresh token of the session.
+// Scopes are the scopes granted to the token, for a stored user the scopes the
+// user may request. ClientID is set when the token was issued to an OAuth client.
+// Audience and Actor are set for tokens obtained by token exchange.
 type User struct {
-	ID    uuid.UUID
-	Ip    string
-	Token string
+	ID        uuid.UUID
+	Ip        string
+	SessionID uuid.UUID
+	TokenID   uuid.UUID
+	ClientID  string
+	Scopes    []string
+	Audience  []string
+	Actor     *Actor
+}
+
+// Actor is the party acting on behalf of the user, RFC 8693 section 4.1.
+// Actor is set when the actor itself was acting for another one.
+type Actor struct {
+	Subject string
+	Actor   *Actor
 }
@@ -0,0 +1,74 @@
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Denylist holds the `jti` of access tokens revoked before their expiry.
+type Denylist interface {
+	Add(ctx context.Context, tokenID string, expiresAt time.Time) error
+	Contains(ctx context.Context, tokenID string) (bool, error)
+}
+
+// MemoryDenylist keeps revoked token IDs in process memory. It suits a single
+// instance deployment; entries are dropped once the token would have expired.
+type MemoryDenylist struct {
+	mu        sync.Mutex
+	tokens    map[string]time.Time
+	interval  time.Duration
+	evictedAt time.Time
+	now       func() time.Time
+}
+
+// NewMemoryDenylist creates a denylist that evicts expired entries at most once per interval.
+func NewMemoryDenylist(interval time.Duration) *MemoryDenylist {
+	return &MemoryDenylist{
+		tokens:   make(map[string]time.Time),
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+func (d *MemoryDenylist) Add(_ context.Context, tokenID string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+
+	d.evict(now)
+
+	if expiresAt.After(now) && expiresAt.After(d.tokens[tokenID]) {
+		d.tokens[tokenID] = expiresAt
+	}
+
+	return nil
+}
+
+func (d *MemoryDenylist) Contains(_ context.Context, tokenID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+
+	d.evict(now)
+
+	expiresAt, ok := d.tokens[tokenID]
+
+	return ok && expiresAt.After(now), nil
+}
+
+func (d *MemoryDenylist) evict(now time.Time) {
+	if now.Sub(d.evictedAt) < d.interval {
+		return
+	}
+
+	for id, expiresAt := range d.tokens {
+		if !expiresAt.After(now) {
+			delete(d.tokens, id)
+		}
+	}
+
+	d.evictedAt = now
+}
@@ -0,0 +1,69 @@
+package token
+
+import (
+	"auth/internal/models"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryDenylist(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	d := NewMemoryDenylist(time.Minute)
+	d.now = func() time.Time { return now }
+
+	assert.NoError(t, d.Add(ctx, "first", now.Add(5*time.Minute)))
+	assert.NoError(t, d.Add(ctx, "second", now.Add(30*time.Second)))
+	assert.NoError(t, d.Add(ctx, "expired", now.Add(-time.Second)))
+
+	revoked, err := d.Contains(ctx, "first")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, _ = d.Contains(ctx, "expired")
+	assert.False(t, revoked)
+
+	revoked, _ = d.Contains(ctx, "unknown")
+	assert.False(t, revoked)
+
+	now = now.Add(2 * time.Minute)
+
+	revoked, _ = d.Contains(ctx, "second")
+	assert.False(t, revoked)
+	assert.Len(t, d.tokens, 1, "expired tokens must be evicted")
+
+	revoked, _ = d.Contains(ctx, "first")
+	assert.True(t, revoked)
+}
+
+func TestService_ParseUserRevoked(t *testing.T) {
+	forEachFormat(t, func(t *testing.T, s *Service) {
+		ctx := context.Background()
+
+		user := &models.User{
+			ID:        uuid.New(),
+			Ip:        "127.0.0.1",
+			SessionID: uuid.New(),
+			TokenID:   uuid.New(),
+		}
+
+		accessToken, err := s.Issue(user)
+		assert.NoError(t, err)
+
+		parsed, err := s.ParseUser(ctx, accessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, user.TokenID, parsed.TokenID)
+
+		assert.NoError(t, s.Revoke(ctx, user.TokenID))
+
+		_, err = s.ParseUser(ctx, accessToken)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+
+		_, err = s.ParseUserWithoutValidation(accessToken)
+		assert.NoError(t, err, "refresh still has to find the session of a revoked token")
+	})
+}
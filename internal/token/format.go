@@ -0,0 +1,152 @@
+package token
+
+import (
+	"auth/internal/config"
+	"auth/pkg/jwt"
+	"auth/pkg/paseto"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	FormatJWT          = "jwt"
+	FormatPasetoPublic = "v4.public"
+	FormatPasetoLocal  = "v4.local"
+
+	// pasetoLocalKeySize is the size of the v4.local key.
+	pasetoLocalKeySize = 32
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported token format")
+
+// Format encodes access tokens. Parsed claims are flat strings with times in
+// Unix seconds and nested claims in JSON, whatever the format is.
+type Format interface {
+	Issue(payload *Payload) (string, error)
+	Parse(token string) (map[string]string, error)
+	ParseWithoutValidation(token string) (map[string]string, error)
+}
+
+// Payload is the content of an access token about to be issued.
+// Objects are claims of other JSON types than string, such as `act`.
+type Payload struct {
+	Subject   string
+	Claims    map[string]string
+	Objects   map[string]interface{}
+	Audience  []string
+	ExpiresIn time.Duration
+}
+
+// jwtFormat issues access tokens with the same service as ID tokens.
+type jwtFormat struct {
+	service *jwt.Service
+}
+
+func (f jwtFormat) Issue(payload *Payload) (string, error) {
+	opts := []jwt.IssueOption{jwt.WithExpiresIn(payload.ExpiresIn)}
+
+	if len(payload.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(payload.Audience...))
+	}
+
+	for name, value := range payload.Objects {
+		opts = append(opts, jwt.WithClaim(name, value))
+	}
+
+	return f.service.IssueToken(payload.Subject, payload.Claims, opts...)
+}
+
+func (f jwtFormat) Parse(token string) (map[string]string, error) {
+	return f.service.ParseTokenClaims(token)
+}
+
+func (f jwtFormat) ParseWithoutValidation(token string) (map[string]string, error) {
+	return f.service.ParseTokenClaimsWithoutValidation(token)
+}
+
+type pasetoFormat struct {
+	service *paseto.Service
+}
+
+func (f pasetoFormat) Issue(payload *Payload) (string, error) {
+	opts := []paseto.IssueOption{paseto.WithExpiresIn(payload.ExpiresIn)}
+
+	if len(payload.Audience) > 0 {
+		opts = append(opts, paseto.WithAudience(payload.Audience...))
+	}
+
+	for name, value := range payload.Objects {
+		opts = append(opts, paseto.WithClaim(name, value))
+	}
+
+	return f.service.IssueToken(payload.Subject, payload.Claims, opts...)
+}
+
+func (f pasetoFormat) Parse(token string) (map[string]string, error) {
+	return f.service.ParseTokenClaims(token)
+}
+
+func (f pasetoFormat) ParseWithoutValidation(token string) (map[string]string, error) {
+	return f.service.ParseTokenClaimsWithoutValidation(token)
+}
+
+// newFormat picks the access token format, JWT access tokens share the
+// service that issues ID tokens.
+func newFormat(cfg *config.JWT, service *jwt.Service) (Format, error) {
+	switch cfg.Format {
+	case "", FormatJWT:
+		return jwtFormat{service: service}, nil
+	case FormatPasetoPublic, FormatPasetoLocal:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, cfg.Format)
+	}
+
+	conf := paseto.NewConfig().
+		SetIssuer(cfg.Issuer).
+		SetAudience(cfg.Audience...).
+		SetTokenExpiresIn(cfg.TokenTTL).
+		SetLeeway(cfg.Leeway).
+		SetMaxAge(cfg.MaxAge)
+
+	if cfg.Format == FormatPasetoLocal {
+		key, err := base64.StdEncoding.DecodeString(cfg.Paseto.LocalKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode paseto key: %w", err)
+		}
+
+		if len(key) != pasetoLocalKeySize {
+			return nil, fmt.Errorf("%w: %s needs a %d byte key", paseto.ErrInvalidKey, FormatPasetoLocal,
+				pasetoLocalKeySize)
+		}
+
+		conf.SetPurpose(paseto.Local).SetLocalKey(key)
+	} else {
+		key, err := loadPasetoKey(cfg.Paseto.PrivateKeyPath, cfg.EphemeralKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		conf.SetPrivateKey(key)
+	}
+
+	return pasetoFormat{service: paseto.NewService(conf)}, nil
+}
+
+// loadPasetoKey reads the Ed25519 key of v4.public tokens. Like the JWT signing
+// key, a missing key is an error unless ephemeral keys are allowed.
+func loadPasetoKey(path string, ephemeral bool) (ed25519.PrivateKey, error) {
+	signer, err := loadPrivateKey(jwt.EdDSA, path, ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := signer.(ed25519.PrivateKey)
+	if !ok {
+		return nil, jwt.ErrInvalidKeyType
+	}
+
+	return key, nil
+}
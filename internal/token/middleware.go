@@ -0,0 +1,45 @@
+package token
+
+import (
+	"auth/pkg/middleware"
+	"context"
+)
+
+// MiddlewareParser lets pkg/middleware authenticate requests with the service
+// itself, unlike a verifier of the published keys it rejects revoked tokens.
+type MiddlewareParser struct {
+	service *Service
+}
+
+func NewMiddlewareParser(service *Service) *MiddlewareParser {
+	return &MiddlewareParser{
+		service: service,
+	}
+}
+
+func (p *MiddlewareParser) ParseAccessToken(ctx context.Context, accessToken string) (*middleware.Token, error) {
+	token, err := p.service.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &middleware.Token{
+		Subject:   token.Subject,
+		TokenID:   token.TokenID.String(),
+		ClientID:  token.ClientID,
+		Scopes:    token.Scopes,
+		Audience:  token.Audience,
+		IssuedAt:  token.IssuedAt,
+		ExpiresAt: token.ExpiresAt,
+	}
+
+	if token.User != nil {
+		parsed.User = &middleware.User{
+			ID:        token.User.ID.String(),
+			SessionID: token.User.SessionID.String(),
+			ClientID:  token.User.ClientID,
+		}
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,77 @@
+package token
+
+import (
+	"auth/internal/models"
+	"auth/pkg/middleware"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareParser_ParseAccessToken(t *testing.T) {
+	forEachFormat(t, func(t *testing.T, s *Service) {
+		ctx := context.Background()
+		p := NewMiddlewareParser(s)
+
+		user := &models.User{
+			ID:        uuid.New(),
+			SessionID: uuid.New(),
+			TokenID:   uuid.New(),
+			ClientID:  "app",
+			Scopes:    []string{"orders:read"},
+			Audience:  []string{"orders"},
+		}
+
+		accessToken, err := s.Issue(user)
+		assert.NoError(t, err)
+
+		token, err := p.ParseAccessToken(ctx, accessToken)
+		assert.NoError(t, err)
+
+		assert.Equal(t, user.ID.String(), token.User.ID)
+		assert.Equal(t, user.SessionID.String(), token.User.SessionID)
+		assert.Equal(t, "app", token.User.ClientID)
+		assert.Equal(t, user.TokenID.String(), token.TokenID)
+		assert.Equal(t, user.Scopes, token.Scopes)
+		assert.Equal(t, user.Audience, token.Audience)
+
+		clientToken, err := s.IssueClient(&models.Client{ID: "billing"}, uuid.New(), []string{"orders:read"})
+		assert.NoError(t, err)
+
+		token, err = p.ParseAccessToken(ctx, clientToken)
+		assert.NoError(t, err)
+		assert.Nil(t, token.User)
+		assert.Equal(t, "billing", token.ClientID)
+	})
+}
+
+func TestMiddlewareParser_Revoked(t *testing.T) {
+	s := testService(t)
+	user := &models.User{ID: uuid.New(), SessionID: uuid.New(), TokenID: uuid.New()}
+
+	accessToken, err := s.Issue(user)
+	assert.NoError(t, err)
+
+	handler := middleware.New(NewMiddlewareParser(s), middleware.NewConfig()).
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+accessToken)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+
+	assert.NoError(t, s.RevokeUntil(context.Background(), user.TokenID, time.Now().Add(time.Minute)))
+
+	assert.Equal(t, http.StatusUnauthorized, serve(), "unlike a verifier, the service knows revoked tokens")
+}
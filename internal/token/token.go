@@ -4,39 +4,503 @@ import (
 	"auth/internal/config"
 	"auth/internal/models"
 	"auth/pkg/jwt"
+	"auth/pkg/middleware"
+	"auth/pkg/paseto"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"os"
+	"slices"
+	"strings"
+	"time"
 )
 
+// Service issues access tokens in the configured format, ID tokens are always JWT.
 type Service struct {
-	service *jwt.Service
+	service  *jwt.Service
+	idTokens *jwt.Service
+	format   Format
+	denylist Denylist
+	tokenTTL time.Duration
+	leeway   time.Duration
 }
 
-func NewJWTService(cfg *config.JWT) *Service {
+func NewJWTService(cfg *config.JWT, denylist Denylist) (*Service, error) {
+	algorithm := jwt.Algorithm(cfg.Algorithm)
+
+	conf := jwt.NewConfig().
+		SetAlgorithm(algorithm).
+		SetSecret(cfg.Secret).
+		SetIssuer(cfg.Issuer).
+		SetAudience(cfg.Audience...).
+		SetTokenExpiresIn(cfg.TokenTTL).
+		SetSessionExpiresIn(cfg.SessionTTL).
+		SetLeeway(cfg.Leeway).
+		SetMaxAge(cfg.MaxAge)
+
+	// PASETO tokens always carry `nbf`, JWTs only when it is enabled
+	if cfg.NotBefore {
+		conf.SetNotBefore(cfg.NotBeforeDelay)
+	}
+
+	if cfg.KeyID != "" {
+		conf.SetKeyID(cfg.KeyID)
+	}
+
+	if len(cfg.Keys) > 0 {
+		ring, err := newKeyRing(cfg.Keys)
+		if err != nil {
+			return nil, err
+		}
+
+		conf.SetKeyRing(ring)
+	} else if algorithm.IsAsymmetric() {
+		key, err := loadPrivateKey(algorithm, cfg.PrivateKeyPath, cfg.EphemeralKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		conf.SetPrivateKey(key)
+	}
+
+	encryptionKey, err := newEncryptionKey(cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	if encryptionKey != nil {
+		conf.SetEncryptionKey(encryptionKey)
+	}
+
+	service := jwt.NewService(conf)
+
+	idTokens, err := newIDTokenService(cfg, service)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := newFormat(cfg, service)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Service{
-		jwt.NewService(
-			jwt.NewConfig().
-				SetSecret(cfg.Secret).
-				SetIssuer(cfg.Issuer).
-				SetTokenExpiresIn(cfg.TokenTTL).
-				SetSessionExpiresIn(cfg.SessionTTL),
-		),
+		service:  service,
+		idTokens: idTokens,
+		format:   format,
+		denylist: denylist,
+		tokenTTL: cfg.TokenTTL,
+		leeway:   cfg.Leeway,
+	}, nil
+}
+
+// defaultIDTokenKeyID is the `kid` of the ID token key when none is configured.
+const defaultIDTokenKeyID = "id-token"
+
+// newIDTokenService returns the service ID tokens are signed with. Clients
+// verify them with the JWKS, which never carries secrets, so while any access
+// token key is symmetric ID tokens get an asymmetric key of their own.
+func newIDTokenService(cfg *config.JWT, service *jwt.Service) (*jwt.Service, error) {
+	if !slices.ContainsFunc(service.KeyRing().Keys(), func(key *jwt.Key) bool {
+		return !key.Algorithm.IsAsymmetric()
+	}) {
+		return service, nil
+	}
+
+	algorithm := jwt.Algorithm(cfg.IDToken.Algorithm)
+	if algorithm == "" {
+		algorithm = jwt.RS256
+	}
+
+	if !algorithm.IsAsymmetric() {
+		return nil, fmt.Errorf("%w: ID tokens cannot be signed with %s", jwt.ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	key, err := loadPrivateKey(algorithm, cfg.IDToken.PrivateKeyPath, cfg.EphemeralKeys)
+	if errors.Is(err, ErrSigningKeyMissing) {
+		return nil, fmt.Errorf("id token key: %w, set jwt.id_token.private_key_path or ID_TOKEN_PRIVATE_KEY_PATH", err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("id token key: %w", err)
+	}
+
+	keyID := cfg.IDToken.KeyID
+	if keyID == "" {
+		keyID = defaultIDTokenKeyID
+	}
+
+	return jwt.NewService(jwt.NewConfig().
+		SetAlgorithm(algorithm).
+		SetPrivateKey(key).
+		SetKeyID(keyID).
+		SetIssuer(cfg.Issuer).
+		SetTokenExpiresIn(cfg.TokenTTL)), nil
+}
+
+// newEncryptionKey returns nil when access tokens are not encrypted. The
+// algorithm and the key length are checked here, so the service fails to start.
+func newEncryptionKey(cfg config.JWTEncryption) (*jwt.EncryptionKey, error) {
+	if cfg.Algorithm == "" {
+		return nil, nil
+	}
+
+	key := &jwt.EncryptionKey{
+		ID:        cfg.KeyID,
+		Algorithm: jwt.KeyManagement(cfg.Algorithm),
+	}
+
+	switch key.Algorithm {
+	case jwt.Direct, jwt.RSAOAEP, jwt.RSAOAEP256:
+	default:
+		return nil, fmt.Errorf("%w: %q", jwt.ErrUnsupportedEncryption, cfg.Algorithm)
+	}
+
+	if key.Algorithm == jwt.Direct {
+		secret, err := base64.StdEncoding.DecodeString(cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode encryption key: %w", err)
+		}
+
+		key.Secret = secret
+
+		return key, key.Validate()
+	}
+
+	data, err := os.ReadFile(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key: %w", err)
+	}
+
+	signer, err := jwt.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := signer.(*rsa.PrivateKey)
+	if !ok {
+		return nil, jwt.ErrInvalidKeyType
 	}
+
+	key.PrivateKey = privateKey
+
+	return key, key.Validate()
+}
+
+func newKeyRing(keys []config.JWTKey) (*jwt.KeyRing, error) {
+	ring, err := jwt.NewKeyRing()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		key := &jwt.Key{
+			ID:          k.ID,
+			Algorithm:   jwt.Algorithm(k.Algorithm),
+			Secret:      []byte(k.Secret),
+			State:       jwt.KeyState(k.State),
+			ActivatesAt: k.ActivatesAt,
+			RetiresAt:   k.RetiresAt,
+		}
+
+		if key.Algorithm.IsAsymmetric() {
+			if k.PrivateKeyPath != "" {
+				key.PrivateKey, err = loadPrivateKey(key.Algorithm, k.PrivateKeyPath, false)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if k.PublicKeyPath != "" {
+				key.PublicKey, err = loadPublicKey(k.PublicKeyPath)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if err = ring.Add(key); err != nil {
+			return nil, fmt.Errorf("invalid jwt key %q: %w", k.ID, err)
+		}
+	}
+
+	return ring, nil
+}
+
+// loadPrivateKey reads the signing key from PEM file. A missing key is an error
+// unless ephemeral keys are allowed, then a fresh key is generated and tokens
+// do not survive a restart nor are they accepted by other replicas.
+func loadPrivateKey(algorithm jwt.Algorithm, path string, ephemeral bool) (crypto.Signer, error) {
+	if path == "" {
+		if !ephemeral {
+			return nil, fmt.Errorf("%w: %s needs a private key", ErrSigningKeyMissing, algorithm)
+		}
+
+		return jwt.GenerateKey(algorithm)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	return jwt.ParsePrivateKeyPEM(data)
+}
+
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+
+	return jwt.ParsePublicKeyPEM(data)
 }
 
 func (s *Service) Issue(user *models.User) (string, error) {
-	return s.service.IssueToken(user.ID.String(), map[string]string{
-		"ip": user.Ip,
+	return s.format.Issue(userPayload(user))
+}
+
+// IssueUntil issues an access token that expires no later than expiresAt, so a
+// token derived from another one, e.g. by token exchange, does not outlive it.
+func (s *Service) IssueUntil(user *models.User, expiresAt time.Time) (string, error) {
+	expiresIn := min(s.tokenTTL, time.Until(expiresAt))
+	if expiresIn < time.Second {
+		return "", jwt.ErrTokenExpired
+	}
+
+	payload := userPayload(user)
+	payload.ExpiresIn = expiresIn
+
+	return s.format.Issue(payload)
+}
+
+func userPayload(user *models.User) *Payload {
+	claims := map[string]string{
+		"ip":  user.Ip,
+		"sid": user.SessionID.String(),
+		"jti": user.TokenID.String(),
+	}
+
+	if user.ClientID != "" {
+		claims["client_id"] = user.ClientID
+	}
+
+	if len(user.Scopes) > 0 {
+		claims["scope"] = strings.Join(user.Scopes, " ")
+	}
+
+	payload := &Payload{
+		Subject:  user.ID.String(),
+		Claims:   claims,
+		Audience: user.Audience,
+	}
+
+	if user.Actor != nil {
+		payload.Objects = map[string]interface{}{"act": newActorClaim(user.Actor)}
+	}
+
+	return payload
+}
+
+// IssueClient issues an access token to the client itself, for calls between
+// services that are not made on behalf of a user.
+func (s *Service) IssueClient(client *models.Client, tokenID uuid.UUID, scopes []string) (string, error) {
+	return s.format.Issue(&Payload{
+		Subject: client.ID,
+		Claims: map[string]string{
+			"client_id": client.ID,
+			"scope":     strings.Join(scopes, " "),
+			"jti":       tokenID.String(),
+		},
+		ExpiresIn: client.TokenTTL,
 	})
 }
 
-func (s *Service) ParseUser(accessToken string) (*models.User, error) {
-	claims, err := s.service.ParseTokenClaims(accessToken)
+// IssueIDToken issues an OpenID Connect ID token for the client the user authorized.
+func (s *Service) IssueIDToken(user *models.User, nonce string, authTime time.Time) (string, error) {
+	claims := map[string]string{
+		"sid": user.SessionID.String(),
+	}
+
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	// the client has to read the ID token, so it is never encrypted
+	return s.idTokens.IssueToken(user.ID.String(), claims, jwt.WithAudience(user.ClientID),
+		jwt.WithClaim("auth_time", authTime.Unix()), jwt.WithoutEncryption())
+}
+
+// Revoke puts the access token on the denylist. Any token with this ID was
+// issued before now, so it expires within the token TTL.
+func (s *Service) Revoke(ctx context.Context, tokenID uuid.UUID) error {
+	return s.RevokeUntil(ctx, tokenID, time.Now().Add(s.tokenTTL))
+}
+
+// RevokeUntil puts the access token on the denylist until its known expiry,
+// e.g. for client tokens whose lifetime differs from the default one. Parsing
+// accepts tokens up to the leeway past `exp`, so the entry is kept that much longer.
+func (s *Service) RevokeUntil(ctx context.Context, tokenID uuid.UUID, expiresAt time.Time) error {
+	if s.denylist == nil || tokenID == uuid.Nil {
+		return nil
+	}
+
+	return s.denylist.Add(ctx, tokenID.String(), expiresAt.Add(s.leeway))
+}
+
+// RevokeSession puts the session on the denylist, which rejects every access
+// token carrying its `sid`, including those derived by token exchange whose
+// `jti` the session does not know. New tokens of a revoked session cannot be
+// issued, so its tokens expire within the token TTL.
+func (s *Service) RevokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	return s.RevokeUntil(ctx, sessionID, time.Now().Add(s.tokenTTL))
+}
+
+// PasetoKeys returns the public key of v4.public access tokens as PASERK,
+// the key set is empty for other formats.
+func (s *Service) PasetoKeys() paseto.KeySet {
+	if format, ok := s.format.(pasetoFormat); ok {
+		return format.service.KeySet()
+	}
+
+	return paseto.KeySet{Keys: []string{}}
+}
+
+// JWKS returns the public keys of access tokens and, when it is a separate one, of ID tokens.
+func (s *Service) JWKS() jwt.JSONWebKeySet {
+	set := s.service.JWKS()
+
+	if s.idTokens != s.service {
+		set.Keys = append(set.Keys, s.idTokens.JWKS().Keys...)
+	}
+
+	return set
+}
+
+func (s *Service) Issuer() string {
+	return s.service.Issuer()
+}
+
+// IDTokenAlgorithms returns the algorithms ID tokens may be signed with.
+func (s *Service) IDTokenAlgorithms() []string {
+	return s.idTokens.Algorithms()
+}
+
+// HasScopes reports whether the parsed token was granted all of the required scopes.
+func (s *Service) HasScopes(token *models.AccessToken, scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(token.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseUser validates the access token and rejects it when it has been revoked.
+func (s *Service) ParseUser(ctx context.Context, accessToken string) (*models.User, error) {
+	token, err := s.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.User == nil {
+		return nil, ErrInvalidTokenPayload
+	}
+
+	return token.User, nil
+}
+
+// ParseAccessToken works like ParseUser, but also returns the registered claims of the token.
+func (s *Service) ParseAccessToken(ctx context.Context, accessToken string) (*models.AccessToken, error) {
+	claims, err := s.format.Parse(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := accessTokenFromClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.denylist == nil {
+		return token, nil
+	}
+
+	ids := []uuid.UUID{token.TokenID}
+	if token.User != nil {
+		ids = append(ids, token.User.SessionID)
+	}
+
+	for _, id := range ids {
+		revoked, err := s.denylist.Contains(ctx, id.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to check denylist: %w", err)
+		}
+
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return token, nil
+}
+
+// ParseUserWithoutValidation verifies only the signature of the access token.
+// It is used on refresh, where the access token is usually already expired
+// but still has to belong to the same session as the refresh token.
+func (s *Service) ParseUserWithoutValidation(accessToken string) (*models.User, error) {
+	claims, err := s.format.ParseWithoutValidation(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return userFromClaims(claims)
+}
+
+// accessTokenFromClaims reads the registered claims by the rules of
+// middleware.TokenFromClaims, so services verifying the published keys read
+// tokens alike. Tokens without `sid` are issued to a client and carry no user.
+func accessTokenFromClaims(claims map[string]string) (*models.AccessToken, error) {
+	parsed, err := middleware.TokenFromClaims(claims)
+	if err != nil {
+		return nil, ErrInvalidTokenPayload
+	}
 
+	tokenID, err := uuid.Parse(parsed.TokenID)
+	if err != nil {
+		return nil, ErrInvalidTokenPayload
+	}
+
+	token := &models.AccessToken{
+		Subject:   parsed.Subject,
+		TokenID:   tokenID,
+		Issuer:    claims["iss"],
+		ClientID:  parsed.ClientID,
+		Scopes:    parsed.Scopes,
+		Audience:  parsed.Audience,
+		IssuedAt:  parsed.IssuedAt,
+		ExpiresAt: parsed.ExpiresAt,
+	}
+
+	if parsed.User == nil {
+		return token, nil
+	}
+
+	token.User, err = userFromClaims(claims)
 	if err != nil {
 		return nil, err
 	}
 
+	return token, nil
+}
+
+func userFromClaims(claims map[string]string) (*models.User, error) {
 	ID, ok := claims["sub"]
 	if !ok {
 		return nil, ErrInvalidTokenPayload
@@ -52,10 +516,71 @@ func (s *Service) ParseUser(accessToken string) (*models.User, error) {
 		return nil, ErrInvalidTokenPayload
 	}
 
-	return &models.User{
-		ID: GUID,
-		Ip: ip,
-	}, nil
+	sessionID, err := uuid.Parse(claims["sid"])
+	if err != nil {
+		return nil, ErrInvalidTokenPayload
+	}
+
+	tokenID, err := uuid.Parse(claims["jti"])
+	if err != nil {
+		return nil, ErrInvalidTokenPayload
+	}
+
+	user := &models.User{
+		ID:        GUID,
+		Ip:        ip,
+		SessionID: sessionID,
+		TokenID:   tokenID,
+		ClientID:  claims["client_id"],
+		Scopes:    strings.Fields(claims["scope"]),
+	}
+
+	if aud, ok := claims["aud"]; ok {
+		user.Audience = middleware.AudienceFromClaim(aud)
+	}
+
+	if act, ok := claims["act"]; ok {
+		var actor actorClaim
+		if err = json.Unmarshal([]byte(act), &actor); err != nil || actor.Subject == "" {
+			return nil, ErrInvalidTokenPayload
+		}
+
+		user.Actor = actor.actor()
+	}
+
+	return user, nil
 }
 
-var ErrInvalidTokenPayload = errors.New("invalid access token payload")
+// actorClaim is the JSON form of the `act` claim.
+type actorClaim struct {
+	Subject string      `json:"sub"`
+	Actor   *actorClaim `json:"act,omitempty"`
+}
+
+func newActorClaim(actor *models.Actor) *actorClaim {
+	if actor == nil {
+		return nil
+	}
+
+	return &actorClaim{
+		Subject: actor.Subject,
+		Actor:   newActorClaim(actor.Actor),
+	}
+}
+
+func (a *actorClaim) actor() *models.Actor {
+	if a == nil {
+		return nil
+	}
+
+	return &models.Actor{
+		Subject: a.Subject,
+		Actor:   a.Actor.actor(),
+	}
+}
+
+var (
+	ErrInvalidTokenPayload = errors.New("invalid access token payload")
+	ErrTokenRevoked        = errors.New("access token is revoked")
+	ErrSigningKeyMissing   = errors.New("signing key is not configured")
+)
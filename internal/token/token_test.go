@@ -0,0 +1,481 @@
+package token
+
+import (
+	"auth/internal/config"
+	"auth/internal/models"
+	"auth/pkg/jwt"
+	"auth/pkg/paseto"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig(format string) *config.JWT {
+	return &config.JWT{
+		Issuer:        "test",
+		Secret:        "secret",
+		Algorithm:     "HS512",
+		TokenTTL:      5 * time.Minute,
+		Format:        format,
+		EphemeralKeys: true,
+		Paseto: config.Paseto{
+			LocalKey: base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")),
+		},
+	}
+}
+
+func testService(t *testing.T) *Service {
+	s, err := NewJWTService(testConfig(FormatJWT), NewMemoryDenylist(time.Minute))
+	assert.NoError(t, err)
+
+	return s
+}
+
+// forEachFormat runs the test against a service per access token format,
+// so all formats have to behave the same.
+func forEachFormat(t *testing.T, test func(t *testing.T, s *Service)) {
+	for _, format := range []string{FormatJWT, FormatPasetoPublic, FormatPasetoLocal} {
+		t.Run(format, func(t *testing.T) {
+			s, err := NewJWTService(testConfig(format), NewMemoryDenylist(time.Minute))
+			assert.NoError(t, err)
+
+			test(t, s)
+		})
+	}
+}
+
+func TestService_IssueClient(t *testing.T) {
+	forEachFormat(t, func(t *testing.T, s *Service) {
+		ctx := context.Background()
+
+		client := &models.Client{
+			ID:       "billing",
+			TokenTTL: time.Hour,
+		}
+
+		tokenID := uuid.New()
+
+		accessToken, err := s.IssueClient(client, tokenID, []string{"orders:read", "orders:write"})
+		assert.NoError(t, err)
+
+		token, err := s.ParseAccessToken(ctx, accessToken)
+		assert.NoError(t, err)
+
+		assert.Nil(t, token.User)
+		assert.Equal(t, "billing", token.Subject)
+		assert.Equal(t, "billing", token.ClientID)
+		assert.Equal(t, tokenID, token.TokenID)
+		assert.Equal(t, []string{"orders:read", "orders:write"}, token.Scopes)
+		assert.Equal(t, time.Hour, token.ExpiresAt.Sub(token.IssuedAt))
+
+		_, err = s.ParseUser(ctx, accessToken)
+		assert.ErrorIs(t, err, ErrInvalidTokenPayload, "client tokens must not be accepted as user tokens")
+
+		assert.NoError(t, s.RevokeUntil(ctx, tokenID, token.ExpiresAt))
+
+		_, err = s.ParseAccessToken(ctx, accessToken)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+	})
+}
+
+func TestService_IssueUntil(t *testing.T) {
+	forEachFormat(t, func(t *testing.T, s *Service) {
+		user := &models.User{
+			ID:        uuid.New(),
+			SessionID: uuid.New(),
+			TokenID:   uuid.New(),
+		}
+
+		expiresAt := time.Now().Add(time.Minute)
+
+		accessToken, err := s.IssueUntil(user, expiresAt)
+		assert.NoError(t, err)
+
+		token, err := s.ParseAccessToken(context.Background(), accessToken)
+		assert.NoError(t, err)
+		assert.False(t, token.ExpiresAt.After(expiresAt), "the token must not outlive expiresAt")
+		assert.WithinDuration(t, expiresAt, token.ExpiresAt, time.Second)
+
+		accessToken, err = s.IssueUntil(user, time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+
+		token, err = s.ParseAccessToken(context.Background(), accessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, 5*time.Minute, token.ExpiresAt.Sub(token.IssuedAt), "the token TTL still applies")
+
+		_, err = s.IssueUntil(user, time.Now())
+		assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+	})
+}
+
+func TestService_IssueIDToken(t *testing.T) {
+	s := testService(t)
+
+	user := &models.User{
+		ID:        uuid.New(),
+		SessionID: uuid.New(),
+		ClientID:  "app",
+	}
+
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	idToken, err := s.IssueIDToken(user, "n-0S6_WzA2Mj", authTime)
+	assert.NoError(t, err)
+
+	_, err = s.ParseUser(context.Background(), idToken)
+	assert.Error(t, err, "id token must not be accepted as access token")
+
+	// clients only have the published keys, access tokens are signed with a secret
+	data, err := json.Marshal(s.JWKS())
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	verifier, err := jwt.NewVerifier(jwt.NewVerifierConfig().SetJWKSFile(path).SetIssuer("test"))
+	assert.NoError(t, err)
+
+	claims, err := verifier.ParseTokenClaims(idToken)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"RS256"}, s.IDTokenAlgorithms())
+
+	assert.Equal(t, user.ID.String(), claims["sub"])
+	assert.Equal(t, "app", claims["aud"])
+	assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+	assert.Equal(t, strconv.FormatInt(authTime.Unix(), 10), claims["auth_time"])
+}
+
+func TestService_IssueDelegated(t *testing.T) {
+	forEachFormat(t, func(t *testing.T, s *Service) {
+		ctx := context.Background()
+
+		user := &models.User{
+			ID:        uuid.New(),
+			SessionID: uuid.New(),
+			TokenID:   uuid.New(),
+			ClientID:  "gateway",
+			Scopes:    []string{"orders:read"},
+			Audience:  []string{"https://orders.example.com"},
+			Actor: &models.Actor{
+				Subject: "gateway",
+				Actor:   &models.Actor{Subject: "frontend"},
+			},
+		}
+
+		accessToken, err := s.Issue(user)
+		assert.NoError(t, err)
+
+		parsed, err := s.ParseUser(ctx, accessToken)
+		assert.NoError(t, err)
+
+		assert.Equal(t, user.ID, parsed.ID)
+		assert.Equal(t, user.Scopes, parsed.Scopes)
+		assert.Equal(t, user.Audience, parsed.Audience)
+		assert.Equal(t, user.Actor, parsed.Actor)
+
+		claims, err := s.format.Parse(accessToken)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "https://orders.example.com", claims["aud"])
+		assert.JSONEq(t, `{"sub":"gateway","act":{"sub":"frontend"}}`, claims["act"])
+	})
+}
+
+func TestService_Audience(t *testing.T) {
+	for _, format := range []string{FormatJWT, FormatPasetoPublic, FormatPasetoLocal} {
+		t.Run(format, func(t *testing.T) {
+			cfg := testConfig(format)
+			cfg.Audience = []string{"https://api.example.com"}
+
+			s, err := NewJWTService(cfg, nil)
+			assert.NoError(t, err)
+
+			accessToken, err := s.Issue(&models.User{ID: uuid.New(), SessionID: uuid.New(), TokenID: uuid.New()})
+			assert.NoError(t, err)
+
+			user, err := s.ParseUser(context.Background(), accessToken)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"https://api.example.com"}, user.Audience)
+
+			// token exchange narrows the audience per token
+			accessToken, err = s.Issue(&models.User{ID: uuid.New(), SessionID: uuid.New(), TokenID: uuid.New(),
+				Audience: []string{"https://orders.example.com"}})
+			assert.NoError(t, err)
+
+			user, err = s.ParseUser(context.Background(), accessToken)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"https://orders.example.com"}, user.Audience)
+		})
+	}
+}
+
+func TestService_NotBefore(t *testing.T) {
+	cfg := testConfig(FormatJWT)
+	cfg.NotBefore = true
+
+	s, err := NewJWTService(cfg, nil)
+	assert.NoError(t, err)
+
+	accessToken, err := s.Issue(&models.User{ID: uuid.New(), SessionID: uuid.New(), TokenID: uuid.New()})
+	assert.NoError(t, err)
+
+	claims, err := s.format.Parse(accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, claims["iat"], claims["nbf"])
+
+	cfg.NotBeforeDelay = time.Minute
+
+	s, err = NewJWTService(cfg, nil)
+	assert.NoError(t, err)
+
+	accessToken, err = s.Issue(&models.User{ID: uuid.New(), SessionID: uuid.New(), TokenID: uuid.New()})
+	assert.NoError(t, err)
+
+	_, err = s.ParseUser(context.Background(), accessToken)
+	assert.Error(t, err, "the token is not valid yet")
+}
+
+func TestService_RevokeSession(t *testing.T) {
+	forEachFormat(t, func(t *testing.T, s *Service) {
+		ctx := context.Background()
+
+		sessionID := uuid.New()
+
+		// an exchanged token has a token id of its own, only the session is shared
+		accessToken, err := s.Issue(&models.User{ID: uuid.New(), SessionID: sessionID, TokenID: uuid.New()})
+		assert.NoError(t, err)
+
+		other, err := s.Issue(&models.User{ID: uuid.New(), SessionID: uuid.New(), TokenID: uuid.New()})
+		assert.NoError(t, err)
+
+		assert.NoError(t, s.RevokeSession(ctx, sessionID))
+
+		_, err = s.ParseUser(ctx, accessToken)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+
+		_, err = s.ParseUser(ctx, other)
+		assert.NoError(t, err)
+	})
+}
+
+// expiryDenylist records until when each revoked ID is kept.
+type expiryDenylist map[string]time.Time
+
+func (d expiryDenylist) Add(_ context.Context, tokenID string, expiresAt time.Time) error {
+	d[tokenID] = expiresAt
+
+	return nil
+}
+
+func (d expiryDenylist) Contains(_ context.Context, tokenID string) (bool, error) {
+	_, ok := d[tokenID]
+
+	return ok, nil
+}
+
+func TestService_RevokeLeeway(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := testConfig(FormatJWT)
+	cfg.Leeway = 5 * time.Second
+
+	denylist := expiryDenylist{}
+
+	s, err := NewJWTService(cfg, denylist)
+	assert.NoError(t, err)
+
+	client := &models.Client{
+		ID:       "billing",
+		TokenTTL: time.Hour,
+	}
+
+	tokenID := uuid.New()
+
+	accessToken, err := s.IssueClient(client, tokenID, nil)
+	assert.NoError(t, err)
+
+	token, err := s.ParseAccessToken(ctx, accessToken)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.RevokeUntil(ctx, tokenID, token.ExpiresAt))
+	assert.Equal(t, token.ExpiresAt.Add(cfg.Leeway), denylist[tokenID.String()],
+		"the token is accepted up to the leeway past its expiry")
+
+	revokedAt := time.Now()
+	tokenID, sessionID := uuid.New(), uuid.New()
+
+	assert.NoError(t, s.Revoke(ctx, tokenID))
+	assert.NoError(t, s.RevokeSession(ctx, sessionID))
+
+	for _, id := range []uuid.UUID{tokenID, sessionID} {
+		assert.False(t, denylist[id.String()].Before(revokedAt.Add(cfg.TokenTTL+cfg.Leeway)))
+	}
+}
+
+func TestService_HasScopes(t *testing.T) {
+	forEachFormat(t, func(t *testing.T, s *Service) {
+		ctx := context.Background()
+
+		accessToken, err := s.Issue(&models.User{
+			ID:        uuid.New(),
+			SessionID: uuid.New(),
+			TokenID:   uuid.New(),
+			Scopes:    []string{"orders:read", "orders:write"},
+		})
+		assert.NoError(t, err)
+
+		token, err := s.ParseAccessToken(ctx, accessToken)
+		assert.NoError(t, err)
+
+		assert.True(t, s.HasScopes(token))
+		assert.True(t, s.HasScopes(token, "orders:read"))
+		assert.True(t, s.HasScopes(token, "orders:write", "orders:read"))
+		assert.False(t, s.HasScopes(token, "orders:read", "billing"))
+
+		accessToken, err = s.Issue(&models.User{ID: uuid.New(), SessionID: uuid.New(), TokenID: uuid.New()})
+		assert.NoError(t, err)
+
+		token, err = s.ParseAccessToken(ctx, accessToken)
+		assert.NoError(t, err)
+
+		assert.Empty(t, token.Scopes)
+		assert.False(t, s.HasScopes(token, "orders:read"))
+	})
+}
+
+func TestService_Encryption(t *testing.T) {
+	s, err := NewJWTService(&config.JWT{
+		Issuer:        "test",
+		Secret:        "secret",
+		Algorithm:     "HS512",
+		TokenTTL:      5 * time.Minute,
+		EphemeralKeys: true,
+		Encryption: config.JWTEncryption{
+			Algorithm: "dir",
+			Key:       base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")),
+		},
+	}, NewMemoryDenylist(time.Minute))
+	assert.NoError(t, err)
+
+	user := &models.User{
+		ID:        uuid.New(),
+		Ip:        "127.0.0.1",
+		SessionID: uuid.New(),
+		TokenID:   uuid.New(),
+		ClientID:  "app",
+	}
+
+	accessToken, err := s.Issue(user)
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(accessToken, "."), 5)
+
+	parsed, err := s.ParseUser(context.Background(), accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", parsed.Ip)
+
+	idToken, err := s.IssueIDToken(user, "", time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(idToken, "."), 3, "id token is read by the client")
+}
+
+func TestService_Formats(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Ip: "127.0.0.1", SessionID: uuid.New(), TokenID: uuid.New()}
+
+	services := make(map[string]*Service)
+
+	for _, format := range []string{FormatJWT, FormatPasetoPublic, FormatPasetoLocal} {
+		s, err := NewJWTService(testConfig(format), nil)
+		assert.NoError(t, err)
+
+		services[format] = s
+	}
+
+	for format, s := range services {
+		accessToken, err := s.Issue(user)
+		assert.NoError(t, err)
+
+		for other, parser := range services {
+			if other == format {
+				continue
+			}
+
+			_, err = parser.ParseUser(context.Background(), accessToken)
+			assert.Error(t, err, "%s token accepted by %s", format, other)
+		}
+	}
+
+	_, err := NewJWTService(testConfig("v3.local"), nil)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestNewJWTService_SigningKey(t *testing.T) {
+	cfg := testConfig(FormatJWT)
+	cfg.Algorithm = "RS256"
+	cfg.EphemeralKeys = false
+
+	_, err := NewJWTService(cfg, nil)
+	assert.ErrorIs(t, err, ErrSigningKeyMissing, "a generated key would change on every restart")
+
+	cfg.EphemeralKeys = true
+
+	_, err = NewJWTService(cfg, nil)
+	assert.NoError(t, err)
+
+	cfg = testConfig(FormatJWT)
+	cfg.EphemeralKeys = false
+
+	_, err = NewJWTService(cfg, nil)
+	assert.ErrorIs(t, err, ErrSigningKeyMissing, "id tokens need a key of their own next to a secret")
+
+	cfg.IDToken.Algorithm = "HS256"
+	cfg.EphemeralKeys = true
+
+	_, err = NewJWTService(cfg, nil)
+	assert.ErrorIs(t, err, jwt.ErrUnsupportedAlgorithm)
+}
+
+func TestNewJWTService_EncryptionKey(t *testing.T) {
+	cfg := testConfig(FormatJWT)
+	cfg.Encryption = config.JWTEncryption{
+		Algorithm: "dir",
+		Key:       base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")),
+	}
+
+	_, err := NewJWTService(cfg, nil)
+	assert.ErrorIs(t, err, jwt.ErrInvalidKey, "A256GCM needs a 32 byte key")
+
+	cfg.Encryption.Algorithm = "A256KW"
+
+	_, err = NewJWTService(cfg, nil)
+	assert.ErrorIs(t, err, jwt.ErrUnsupportedEncryption)
+}
+
+func TestNewJWTService_PasetoKey(t *testing.T) {
+	cfg := testConfig(FormatPasetoPublic)
+	cfg.EphemeralKeys = false
+
+	_, err := NewJWTService(cfg, nil)
+	assert.ErrorIs(t, err, ErrSigningKeyMissing, "a generated key would change on every restart")
+
+	cfg = testConfig(FormatPasetoLocal)
+	cfg.Paseto.LocalKey = ""
+
+	_, err = NewJWTService(cfg, nil)
+	assert.ErrorIs(t, err, paseto.ErrInvalidKey)
+
+	s, err := NewJWTService(testConfig(FormatPasetoLocal), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, s.PasetoKeys().Keys, "v4.local keys are secret")
+
+	s, err = NewJWTService(testConfig(FormatPasetoPublic), nil)
+	assert.NoError(t, err)
+	assert.Len(t, s.PasetoKeys().Keys, 1)
+}
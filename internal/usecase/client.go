@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"auth/internal/models"
+	"auth/internal/usecase/repo/postgres"
+	"context"
+	"fmt"
+)
+
+type ClientUseCase struct {
+	repo ClientsRepo
+}
+
+var _ ClientsRepo = (*postgres.ClientRepo)(nil)
+
+func NewClientUseCase(repo ClientsRepo) *ClientUseCase {
+	return &ClientUseCase{repo: repo}
+}
+
+type ClientsRepo interface {
+	Add(ctx context.Context, client *models.Client) error
+	GetByID(ctx context.Context, ID string) (*models.Client, error)
+}
+
+func (c ClientUseCase) Add(ctx context.Context, client *models.Client) error {
+	const op = "ClientUseCase - Add"
+
+	err := c.repo.Add(ctx, client)
+	if err != nil {
+		return fmt.Errorf("%s - c.repo.Add: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c ClientUseCase) GetByID(ctx context.Context, ID string) (*models.Client, error) {
+	const op = "ClientUseCase - GetByID"
+
+	client, err := c.repo.GetByID(ctx, ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s - c.repo.GetByID: %w", op, err)
+	}
+
+	return client, nil
+}
@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"auth/internal/models"
+	"auth/internal/usecase/repo/postgres"
+	"context"
+	"fmt"
+)
+
+type CodeUseCase struct {
+	repo CodesRepo
+}
+
+var _ CodesRepo = (*postgres.CodeRepo)(nil)
+
+func NewCodeUseCase(repo CodesRepo) *CodeUseCase {
+	return &CodeUseCase{repo: repo}
+}
+
+type CodesRepo interface {
+	Add(ctx context.Context, code *models.AuthorizationCode) error
+	Consume(ctx context.Context, code string) (*models.AuthorizationCode, error)
+}
+
+func (c CodeUseCase) Add(ctx context.Context, code *models.AuthorizationCode) error {
+	const op = "CodeUseCase - Add"
+
+	err := c.repo.Add(ctx, code)
+	if err != nil {
+		return fmt.Errorf("%s - c.repo.Add: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c CodeUseCase) Consume(ctx context.Context, code string) (*models.AuthorizationCode, error) {
+	const op = "CodeUseCase - Consume"
+
+	consumed, err := c.repo.Consume(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%s - c.repo.Consume: %w", op, err)
+	}
+
+	return consumed, nil
+}
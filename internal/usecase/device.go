@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"auth/internal/models"
+	"auth/internal/usecase/repo/postgres"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type DeviceUseCase struct {
+	repo DevicesRepo
+}
+
+var _ DevicesRepo = (*postgres.DeviceRepo)(nil)
+
+func NewDeviceUseCase(repo DevicesRepo) *DeviceUseCase {
+	return &DeviceUseCase{repo: repo}
+}
+
+type DevicesRepo interface {
+	Add(ctx context.Context, device *models.DeviceCode) error
+	GetByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error)
+	Poll(ctx context.Context, code string) (*models.DeviceCode, error)
+	SlowDown(ctx context.Context, code string, step time.Duration) error
+	Approve(ctx context.Context, userCode string, userID uuid.UUID, status models.DeviceCodeStatus) error
+	Consume(ctx context.Context, code string) error
+}
+
+func (d DeviceUseCase) Add(ctx context.Context, device *models.DeviceCode) error {
+	const op = "DeviceUseCase - Add"
+
+	err := d.repo.Add(ctx, device)
+	if err != nil {
+		return fmt.Errorf("%s - d.repo.Add: %w", op, err)
+	}
+
+	return nil
+}
+
+func (d DeviceUseCase) GetByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error) {
+	const op = "DeviceUseCase - GetByUserCode"
+
+	device, err := d.repo.GetByUserCode(ctx, userCode)
+	if err != nil {
+		return nil, fmt.Errorf("%s - d.repo.GetByUserCode: %w", op, err)
+	}
+
+	return device, nil
+}
+
+func (d DeviceUseCase) Poll(ctx context.Context, code string) (*models.DeviceCode, error) {
+	const op = "DeviceUseCase - Poll"
+
+	device, err := d.repo.Poll(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%s - d.repo.Poll: %w", op, err)
+	}
+
+	return device, nil
+}
+
+func (d DeviceUseCase) SlowDown(ctx context.Context, code string, step time.Duration) error {
+	const op = "DeviceUseCase - SlowDown"
+
+	err := d.repo.SlowDown(ctx, code, step)
+	if err != nil {
+		return fmt.Errorf("%s - d.repo.SlowDown: %w", op, err)
+	}
+
+	return nil
+}
+
+func (d DeviceUseCase) Approve(ctx context.Context, userCode string, userID uuid.UUID, status models.DeviceCodeStatus) error {
+	const op = "DeviceUseCase - Approve"
+
+	err := d.repo.Approve(ctx, userCode, userID, status)
+	if err != nil {
+		return fmt.Errorf("%s - d.repo.Approve: %w", op, err)
+	}
+
+	return nil
+}
+
+func (d DeviceUseCase) Consume(ctx context.Context, code string) error {
+	const op = "DeviceUseCase - Consume"
+
+	err := d.repo.Consume(ctx, code)
+	if err != nil {
+		return fmt.Errorf("%s - d.repo.Consume: %w", op, err)
+	}
+
+	return nil
+}
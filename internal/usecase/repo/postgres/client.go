@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"auth/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+type ClientRepo struct {
+	*sql.DB
+}
+
+func NewClientRepo(db *sql.DB) *ClientRepo {
+	return &ClientRepo{db}
+}
+
+func (c ClientRepo) Add(ctx context.Context, client *models.Client) error {
+	const op = "ClientRepo - Add"
+
+	query := "INSERT INTO clients (id, secret, name, scopes, token_ttl, redirect_uris, audiences, public) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+
+	_, err := c.ExecContext(ctx, query, client.ID, client.Secret, client.Name, pq.Array(client.Scopes),
+		int64(client.TokenTTL.Seconds()), pq.Array(client.RedirectURIs), pq.Array(client.Audiences), client.Public)
+	if err != nil {
+		return fmt.Errorf("%s - c.ExecContext: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c ClientRepo) GetByID(ctx context.Context, ID string) (*models.Client, error) {
+	const op = "ClientRepo - GetByID"
+
+	query := "SELECT id, secret, name, scopes, token_ttl, redirect_uris, audiences, public FROM clients " +
+		"WHERE id = $1"
+
+	client := &models.Client{}
+
+	var tokenTTL int64
+
+	err := c.QueryRowContext(ctx, query, ID).Scan(&client.ID, &client.Secret, &client.Name, pq.Array(&client.Scopes),
+		&tokenTTL, pq.Array(&client.RedirectURIs), pq.Array(&client.Audiences), &client.Public)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s - c.QueryRowContext: %w", op, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - c.QueryRowContext: %w", op, err)
+	}
+
+	client.TokenTTL = time.Duration(tokenTTL) * time.Second
+
+	return client, nil
+}
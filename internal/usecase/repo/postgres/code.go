@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"auth/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+type CodeRepo struct {
+	*sql.DB
+}
+
+func NewCodeRepo(db *sql.DB) *CodeRepo {
+	return &CodeRepo{db}
+}
+
+const codeColumns = "code, client_id, user_id, session_id, redirect_uri, scopes, code_challenge, nonce, " +
+	"auth_time, expires_at, used_at IS NOT NULL"
+
+func scanCode(row interface{ Scan(dest ...any) error }) (*models.AuthorizationCode, error) {
+	code := &models.AuthorizationCode{}
+
+	err := row.Scan(&code.Code, &code.ClientID, &code.UserID, &code.SessionID, &code.RedirectURI,
+		pq.Array(&code.Scopes), &code.CodeChallenge, &code.Nonce, &code.AuthTime, &code.ExpiresAt, &code.Used)
+	if err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// Add stores the code and drops the codes that have already expired.
+func (c CodeRepo) Add(ctx context.Context, code *models.AuthorizationCode) error {
+	const op = "CodeRepo - Add"
+
+	_, err := c.ExecContext(ctx, "DELETE FROM authorization_codes WHERE expires_at <= now()")
+	if err != nil {
+		return fmt.Errorf("%s - c.ExecContext: %w", op, err)
+	}
+
+	query := "INSERT INTO authorization_codes (code, client_id, user_id, session_id, redirect_uri, scopes, " +
+		"code_challenge, nonce, auth_time, expires_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+
+	_, err = c.ExecContext(ctx, query, code.Code, code.ClientID, code.UserID, code.SessionID, code.RedirectURI,
+		pq.Array(code.Scopes), code.CodeChallenge, code.Nonce, code.AuthTime, code.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s - c.ExecContext: %w", op, err)
+	}
+
+	return nil
+}
+
+// Consume marks the code as used and returns it. A code that was already used
+// is returned with Used set, so its replay can be detected.
+func (c CodeRepo) Consume(ctx context.Context, code string) (*models.AuthorizationCode, error) {
+	const op = "CodeRepo - Consume"
+
+	query := "UPDATE authorization_codes SET used_at = now() " +
+		"WHERE code = $1 AND used_at IS NULL " +
+		"RETURNING " + codeColumns
+
+	consumed, err := scanCode(c.QueryRowContext(ctx, query, code))
+	if err == nil {
+		// RETURNING reports the row after the update, the code was unused until now
+		consumed.Used = false
+
+		return consumed, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s - c.QueryRowContext: %w", op, err)
+	}
+
+	query = "SELECT " + codeColumns + " FROM authorization_codes " +
+		"WHERE code = $1"
+
+	consumed, err = scanCode(c.QueryRowContext(ctx, query, code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s - c.QueryRowContext: %w", op, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - c.QueryRowContext: %w", op, err)
+	}
+
+	return consumed, nil
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type DenylistRepo struct {
+	*sql.DB
+}
+
+func NewDenylistRepo(db *sql.DB) *DenylistRepo {
+	return &DenylistRepo{db}
+}
+
+// Add stores the revoked token ID and drops the entries of tokens that have already expired.
+func (d DenylistRepo) Add(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	const op = "DenylistRepo - Add"
+
+	_, err := d.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= now()")
+	if err != nil {
+		return fmt.Errorf("%s - d.ExecContext: %w", op, err)
+	}
+
+	query := "INSERT INTO revoked_tokens (id, expires_at) " +
+		"VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)"
+
+	_, err = d.ExecContext(ctx, query, tokenID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s - d.ExecContext: %w", op, err)
+	}
+
+	return nil
+}
+
+func (d DenylistRepo) Contains(ctx context.Context, tokenID string) (bool, error) {
+	const op = "DenylistRepo - Contains"
+
+	query := "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE id = $1 AND expires_at > now())"
+
+	var revoked bool
+
+	err := d.QueryRowContext(ctx, query, tokenID).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("%s - d.QueryRowContext: %w", op, err)
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"auth/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+type DeviceRepo struct {
+	*sql.DB
+}
+
+func NewDeviceRepo(db *sql.DB) *DeviceRepo {
+	return &DeviceRepo{db}
+}
+
+const deviceColumns = "device_code, user_code, client_id, scopes, user_id, status, poll_interval, last_polled_at, " +
+	"expires_at"
+
+func scanDevice(row interface{ Scan(dest ...any) error }) (*models.DeviceCode, error) {
+	device := &models.DeviceCode{}
+
+	var (
+		userID       uuid.NullUUID
+		interval     int64
+		lastPolledAt sql.NullTime
+	)
+
+	err := row.Scan(&device.Code, &device.UserCode, &device.ClientID, pq.Array(&device.Scopes), &userID,
+		&device.Status, &interval, &lastPolledAt, &device.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	device.UserID = userID.UUID
+	device.Interval = time.Duration(interval) * time.Second
+	device.LastPolledAt = lastPolledAt.Time
+
+	return device, nil
+}
+
+// Add stores the device code and drops the codes that have already expired.
+func (d DeviceRepo) Add(ctx context.Context, device *models.DeviceCode) error {
+	const op = "DeviceRepo - Add"
+
+	_, err := d.ExecContext(ctx, "DELETE FROM device_codes WHERE expires_at <= now()")
+	if err != nil {
+		return fmt.Errorf("%s - d.ExecContext: %w", op, err)
+	}
+
+	query := "INSERT INTO device_codes (device_code, user_code, client_id, scopes, status, poll_interval, expires_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7)"
+
+	_, err = d.ExecContext(ctx, query, device.Code, device.UserCode, device.ClientID, pq.Array(device.Scopes),
+		device.Status, int64(device.Interval.Seconds()), device.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s - d.ExecContext: %w", op, err)
+	}
+
+	return nil
+}
+
+func (d DeviceRepo) GetByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error) {
+	const op = "DeviceRepo - GetByUserCode"
+
+	query := "SELECT " + deviceColumns + " FROM device_codes " +
+		"WHERE user_code = $1"
+
+	device, err := scanDevice(d.QueryRowContext(ctx, query, userCode))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s - d.QueryRowContext: %w", op, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - d.QueryRowContext: %w", op, err)
+	}
+
+	return device, nil
+}
+
+// Poll records a poll of the device and returns the code as it was before,
+// so the caller can tell whether the device polls faster than allowed.
+func (d DeviceRepo) Poll(ctx context.Context, code string) (*models.DeviceCode, error) {
+	const op = "DeviceRepo - Poll"
+
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s - d.BeginTx: %w", op, err)
+	}
+
+	defer tx.Rollback()
+
+	query := "SELECT " + deviceColumns + " FROM device_codes " +
+		"WHERE device_code = $1 FOR UPDATE"
+
+	device, err := scanDevice(tx.QueryRowContext(ctx, query, code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s - tx.QueryRowContext: %w", op, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - tx.QueryRowContext: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE device_codes SET last_polled_at = now() WHERE device_code = $1", code)
+	if err != nil {
+		return nil, fmt.Errorf("%s - tx.ExecContext: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s - tx.Commit: %w", op, err)
+	}
+
+	return device, nil
+}
+
+// SlowDown increases the interval the device has to wait between polls.
+func (d DeviceRepo) SlowDown(ctx context.Context, code string, step time.Duration) error {
+	const op = "DeviceRepo - SlowDown"
+
+	query := "UPDATE device_codes SET poll_interval = poll_interval + $1 " +
+		"WHERE device_code = $2"
+
+	_, err := d.ExecContext(ctx, query, int64(step.Seconds()), code)
+	if err != nil {
+		return fmt.Errorf("%s - d.ExecContext: %w", op, err)
+	}
+
+	return nil
+}
+
+// Approve records the decision of the user on a pending code. It fails with
+// ErrNotFound when the code is no longer pending or has expired.
+func (d DeviceRepo) Approve(ctx context.Context, userCode string, userID uuid.UUID, status models.DeviceCodeStatus) error {
+	const op = "DeviceRepo - Approve"
+
+	query := "UPDATE device_codes SET status = $1, user_id = $2 " +
+		"WHERE user_code = $3 AND status = $4 AND expires_at > now()"
+
+	res, err := d.ExecContext(ctx, query, status, userID, userCode, models.DevicePending)
+	if err != nil {
+		return fmt.Errorf("%s - d.ExecContext: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s - res.RowsAffected: %w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s - d.ExecContext: %w", op, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Consume marks an approved code as used, so it is exchanged for tokens only once.
+func (d DeviceRepo) Consume(ctx context.Context, code string) error {
+	const op = "DeviceRepo - Consume"
+
+	query := "UPDATE device_codes SET status = $1 " +
+		"WHERE device_code = $2 AND status = $3"
+
+	res, err := d.ExecContext(ctx, query, models.DeviceUsed, code, models.DeviceApproved)
+	if err != nil {
+		return fmt.Errorf("%s - d.ExecContext: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s - res.RowsAffected: %w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s - d.ExecContext: %w", op, models.ErrNotFound)
+	}
+
+	return nil
+}
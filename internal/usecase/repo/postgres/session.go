@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"auth/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+type SessionRepo struct {
+	*sql.DB
+}
+
+func NewSessionRepo(db *sql.DB) *SessionRepo {
+	return &SessionRepo{db}
+}
+
+const sessionColumns = "id, user_id, token, token_id, access_token_id, ip, user_agent, created_at, last_used_at, " +
+	"expires_at, revoked_at IS NOT NULL, client_id, scopes"
+
+func scanSession(row interface{ Scan(dest ...any) error }) (*models.Session, error) {
+	session := &models.Session{}
+
+	err := row.Scan(&session.ID, &session.UserID, &session.Token, &session.TokenID, &session.AccessTokenID, &session.Ip,
+		&session.UserAgent, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.Revoked,
+		&session.ClientID, pq.Array(&session.Scopes))
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s SessionRepo) Add(ctx context.Context, session *models.Session) error {
+	const op = "SessionRepo - Add"
+
+	query := "INSERT INTO sessions (id, user_id, token, token_id, access_token_id, ip, user_agent, expires_at, " +
+		"client_id, scopes) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+
+	_, err := s.ExecContext(ctx, query, session.ID, session.UserID, session.Token, session.TokenID,
+		session.AccessTokenID, session.Ip, session.UserAgent, session.ExpiresAt, session.ClientID,
+		pq.Array(session.Scopes))
+	if err != nil {
+		return fmt.Errorf("%s - s.ExecContext: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s SessionRepo) GetByID(ctx context.Context, ID string) (*models.Session, error) {
+	const op = "SessionRepo - GetByID"
+
+	query := "SELECT " + sessionColumns + " FROM sessions " +
+		"WHERE id = $1"
+
+	session, err := scanSession(s.QueryRowContext(ctx, query, ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s - s.QueryRowContext: %w", op, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - s.QueryRowContext: %w", op, err)
+	}
+
+	return session, nil
+}
+
+func (s SessionRepo) GetByTokenID(ctx context.Context, tokenID string) (*models.Session, error) {
+	const op = "SessionRepo - GetByTokenID"
+
+	query := "SELECT " + sessionColumns + " FROM sessions " +
+		"WHERE token_id = $1"
+
+	session, err := scanSession(s.QueryRowContext(ctx, query, tokenID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s - s.QueryRowContext: %w", op, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - s.QueryRowContext: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// Rotate replaces the consumed refresh token of the session with the new one and
+// remembers the consumed token. The update only succeeds while the consumed
+// token is still the current one, so a token cannot be exchanged twice.
+func (s SessionRepo) Rotate(ctx context.Context, session *models.Session, consumed *models.RefreshToken) error {
+	const op = "SessionRepo - Rotate"
+
+	tx, err := s.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s - s.BeginTx: %w", op, err)
+	}
+
+	defer tx.Rollback()
+
+	query := "UPDATE sessions SET token = $1, token_id = $2, access_token_id = $3, ip = $4, user_agent = $5, " +
+		"expires_at = $6, last_used_at = now() " +
+		"WHERE id = $7 AND token_id = $8 AND revoked_at IS NULL"
+
+	res, err := tx.ExecContext(ctx, query, session.Token, session.TokenID, session.AccessTokenID, session.Ip,
+		session.UserAgent, session.ExpiresAt, session.ID, consumed.ID)
+	if err != nil {
+		return fmt.Errorf("%s - tx.ExecContext: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s - res.RowsAffected: %w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s - tx.ExecContext: %w", op, models.ErrNotFound)
+	}
+
+	query = "INSERT INTO consumed_refresh_tokens (id, session_id, user_id, token, expires_at) " +
+		"VALUES ($1, $2, $3, $4, $5)"
+
+	_, err = tx.ExecContext(ctx, query, consumed.ID, consumed.SessionID, consumed.UserID, consumed.Token,
+		consumed.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s - tx.ExecContext: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s - tx.Commit: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s SessionRepo) GetConsumedToken(ctx context.Context, ID string) (*models.RefreshToken, error) {
+	const op = "SessionRepo - GetConsumedToken"
+
+	query := "SELECT id, session_id, user_id, token, expires_at FROM consumed_refresh_tokens " +
+		"WHERE id = $1"
+
+	token := &models.RefreshToken{}
+
+	err := s.QueryRowContext(ctx, query, ID).
+		Scan(&token.ID, &token.SessionID, &token.UserID, &token.Token, &token.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s - s.QueryRowContext: %w", op, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s - s.QueryRowContext: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// Revoke ends the session together with its whole refresh token family.
+func (s SessionRepo) Revoke(ctx context.Context, ID string) error {
+	const op = "SessionRepo - Revoke"
+
+	query := "UPDATE sessions SET revoked_at = now() " +
+		"WHERE id = $1 AND revoked_at IS NULL"
+
+	_, err := s.ExecContext(ctx, query, ID)
+	if err != nil {
+		return fmt.Errorf("%s - s.ExecContext: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeByUser ends all sessions of the user. The returned sessions carry only
+// their IDs and access token IDs, so the tokens can be put on the denylist.
+func (s SessionRepo) RevokeByUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	const op = "SessionRepo - RevokeByUser"
+
+	query := "UPDATE sessions SET revoked_at = now() " +
+		"WHERE user_id = $1 AND revoked_at IS NULL " +
+		"RETURNING id, access_token_id"
+
+	rows, err := s.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s - s.QueryContext: %w", op, err)
+	}
+
+	defer rows.Close()
+
+	var sessions []*models.Session
+
+	for rows.Next() {
+		session := &models.Session{}
+
+		if err = rows.Scan(&session.ID, &session.AccessTokenID); err != nil {
+			return nil, fmt.Errorf("%s - rows.Scan: %w", op, err)
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s - rows.Err: %w", op, err)
+	}
+
+	return sessions, nil
+}
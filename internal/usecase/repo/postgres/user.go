@@ -4,7 +4,9 @@ import (
 	"auth/internal/models"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/lib/pq"
 )
 
 type UserRepo struct {
@@ -18,11 +20,10 @@ func NewUserRepo(db *sql.DB) *UserRepo {
 func (u UserRepo) Add(ctx context.Context, user *models.User) error {
 	const op = "UserRepo - Add"
 
-	query := "INSERT INTO users (id, token) " +
-		"VALUES ($1, $2) ON CONFLICT (id) DO UPDATE " +
-		"SET token = excluded.token"
+	query := "INSERT INTO users (id) " +
+		"VALUES ($1) ON CONFLICT (id) DO NOTHING"
 
-	err := u.QueryRowContext(ctx, query, user.ID.String(), user.Token).Err()
+	err := u.QueryRowContext(ctx, query, user.ID.String()).Err()
 	if err != nil {
 		return fmt.Errorf("%s - u.QueryRowContext: %w", op, err)
 	}
@@ -30,23 +31,45 @@ func (u UserRepo) Add(ctx context.Context, user *models.User) error {
 	return nil
 }
 
-func (u UserRepo) GetByGUID(ctx context.Context, GUID string) (string, error) {
+func (u UserRepo) GetByGUID(ctx context.Context, GUID string) (*models.User, error) {
 	const op = "UserRepo - GetByGUID"
 
-	query := "SELECT token FROM users " +
+	query := "SELECT id, scopes FROM users " +
 		"WHERE id = $1"
 
-	rows, err := u.QueryContext(ctx, query, GUID)
+	user := &models.User{}
+
+	err := u.QueryRowContext(ctx, query, GUID).Scan(&user.ID, pq.Array(&user.Scopes))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s - u.QueryRowContext: %w", op, models.ErrNotFound)
+	}
 	if err != nil {
-		return "", fmt.Errorf("%s - u.QueryContext :%w", op, err)
+		return nil, fmt.Errorf("%s - u.QueryRowContext: %w", op, err)
 	}
 
-	defer rows.Close()
+	return user, nil
+}
+
+// SetScopes replaces the scopes the user may request in access tokens.
+func (u UserRepo) SetScopes(ctx context.Context, GUID string, scopes []string) error {
+	const op = "UserRepo - SetScopes"
+
+	query := "UPDATE users SET scopes = $1 " +
+		"WHERE id = $2"
+
+	res, err := u.ExecContext(ctx, query, pq.Array(scopes), GUID)
+	if err != nil {
+		return fmt.Errorf("%s - u.ExecContext: %w", op, err)
+	}
 
-	var token string
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s - res.RowsAffected: %w", op, err)
+	}
 
-	rows.Next()
-	rows.Scan(&token)
+	if affected == 0 {
+		return fmt.Errorf("%s - u.ExecContext: %w", op, models.ErrNotFound)
+	}
 
-	return token, err
+	return nil
 }
@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"auth/internal/models"
+	"auth/internal/usecase/repo/postgres"
+	"context"
+	"fmt"
+)
+
+type SessionUseCase struct {
+	repo SessionsRepo
+}
+
+var _ SessionsRepo = (*postgres.SessionRepo)(nil)
+
+func NewSessionUseCase(repo SessionsRepo) *SessionUseCase {
+	return &SessionUseCase{repo: repo}
+}
+
+type SessionsRepo interface {
+	Add(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, ID string) (*models.Session, error)
+	GetByTokenID(ctx context.Context, tokenID string) (*models.Session, error)
+	Rotate(ctx context.Context, session *models.Session, consumed *models.RefreshToken) error
+	GetConsumedToken(ctx context.Context, ID string) (*models.RefreshToken, error)
+	Revoke(ctx context.Context, ID string) error
+	RevokeByUser(ctx context.Context, userID string) ([]*models.Session, error)
+}
+
+func (s SessionUseCase) Add(ctx context.Context, session *models.Session) error {
+	const op = "SessionUseCase - Add"
+
+	err := s.repo.Add(ctx, session)
+	if err != nil {
+		return fmt.Errorf("%s - s.repo.Add: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s SessionUseCase) GetByID(ctx context.Context, ID string) (*models.Session, error) {
+	const op = "SessionUseCase - GetByID"
+
+	session, err := s.repo.GetByID(ctx, ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s - s.repo.GetByID: %w", op, err)
+	}
+
+	return session, nil
+}
+
+func (s SessionUseCase) GetByTokenID(ctx context.Context, tokenID string) (*models.Session, error) {
+	const op = "SessionUseCase - GetByTokenID"
+
+	session, err := s.repo.GetByTokenID(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("%s - s.repo.GetByTokenID: %w", op, err)
+	}
+
+	return session, nil
+}
+
+func (s SessionUseCase) Rotate(ctx context.Context, session *models.Session, consumed *models.RefreshToken) error {
+	const op = "SessionUseCase - Rotate"
+
+	err := s.repo.Rotate(ctx, session, consumed)
+	if err != nil {
+		return fmt.Errorf("%s - s.repo.Rotate: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s SessionUseCase) GetConsumedToken(ctx context.Context, ID string) (*models.RefreshToken, error) {
+	const op = "SessionUseCase - GetConsumedToken"
+
+	token, err := s.repo.GetConsumedToken(ctx, ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s - s.repo.GetConsumedToken: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func (s SessionUseCase) Revoke(ctx context.Context, ID string) error {
+	const op = "SessionUseCase - Revoke"
+
+	err := s.repo.Revoke(ctx, ID)
+	if err != nil {
+		return fmt.Errorf("%s - s.repo.Revoke: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s SessionUseCase) RevokeByUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	const op = "SessionUseCase - RevokeByUser"
+
+	sessions, err := s.repo.RevokeByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s - s.repo.RevokeByUser: %w", op, err)
+	}
+
+	return sessions, nil
+}
@@ -5,7 +5,6 @@ import (
 	"auth/internal/usecase/repo/postgres"
 	"context"
 	"fmt"
-	"github.com/google/uuid"
 )
 
 type UserUseCase struct {
@@ -20,7 +19,8 @@ func NewUserUseCase(repo UsersRepo) *UserUseCase {
 
 type UsersRepo interface {
 	Add(ctx context.Context, user *models.User) error
-	GetByGUID(ctx context.Context, GUID string) (string, error)
+	GetByGUID(ctx context.Context, GUID string) (*models.User, error)
+	SetScopes(ctx context.Context, GUID string, scopes []string) error
 }
 
 func (u UserUseCase) Add(ctx context.Context, user *models.User) error {
@@ -37,20 +37,21 @@ func (u UserUseCase) Add(ctx context.Context, user *models.User) error {
 func (u UserUseCase) GetByGUID(ctx context.Context, GUID string) (*models.User, error) {
 	const op = "UserUseCase - GetByGUID"
 
-	token, err := u.repo.GetByGUID(ctx, GUID)
+	user, err := u.repo.GetByGUID(ctx, GUID)
 	if err != nil {
 		return nil, fmt.Errorf("%s - u.repo.GetByGUID: %w", op, err)
 	}
 
-	ID, err := uuid.Parse(GUID)
-	if err != nil {
-		return nil, fmt.Errorf("%s - uuid.Parse: %w", op, err)
-	}
+	return user, nil
+}
 
-	user := &models.User{
-		ID:    ID,
-		Token: token,
+func (u UserUseCase) SetScopes(ctx context.Context, GUID string, scopes []string) error {
+	const op = "UserUseCase - SetScopes"
+
+	err := u.repo.SetScopes(ctx, GUID, scopes)
+	if err != nil {
+		return fmt.Errorf("%s - u.repo.SetScopes: %w", op, err)
 	}
 
-	return user, nil
+	return nil
 }
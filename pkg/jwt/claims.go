@@ -0,0 +1,134 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+// NumericDate is a JSON number of seconds since the epoch, as used by `exp`, `nbf` and `iat`.
+type NumericDate = jwt.NumericDate
+
+func NewNumericDate(t time.Time) *NumericDate {
+	return jwt.NewNumericDate(t)
+}
+
+// Claims is implemented by structs embedding RegisteredClaims, so tokens can
+// be issued from and parsed into types with claims of any JSON type.
+type Claims interface {
+	jwt.Claims
+	registered() *RegisteredClaims
+}
+
+// RegisteredClaims are the claims of RFC 7519 section 4.1. On issue `iss` is
+// set to the issuer of the service, other claims left empty are filled from
+// the config and the issue options.
+type RegisteredClaims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+}
+
+func (c *RegisteredClaims) registered() *RegisteredClaims {
+	return c
+}
+
+func (c RegisteredClaims) GetExpirationTime() (*jwt.NumericDate, error) {
+	return c.ExpiresAt, nil
+}
+
+func (c RegisteredClaims) GetNotBefore() (*jwt.NumericDate, error) {
+	return c.NotBefore, nil
+}
+
+func (c RegisteredClaims) GetIssuedAt() (*jwt.NumericDate, error) {
+	return c.IssuedAt, nil
+}
+
+func (c RegisteredClaims) GetAudience() (jwt.ClaimStrings, error) {
+	return jwt.ClaimStrings(c.Audience), nil
+}
+
+func (c RegisteredClaims) GetIssuer() (string, error) {
+	return c.Issuer, nil
+}
+
+func (c RegisteredClaims) GetSubject() (string, error) {
+	return c.Subject, nil
+}
+
+// Audience is the `aud` claim, a single audience is written as a string.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var audience jwt.ClaimStrings
+	if err := audience.UnmarshalJSON(data); err != nil {
+		return ErrInvalidType
+	}
+
+	*a = Audience(audience)
+
+	return nil
+}
+
+// issuedClaims are the claims of a token about to be signed. The registered
+// claims are a filled copy, so the claims of the caller can be issued again,
+// and the claims of WithClaim and the map API are added on top.
+type issuedClaims struct {
+	RegisteredClaims
+	claims Claims
+	extra  map[string]interface{}
+}
+
+func (c issuedClaims) MarshalJSON() ([]byte, error) {
+	merged := make(map[string]interface{})
+
+	for _, claims := range []interface{}{c.claims, c.RegisteredClaims} {
+		data, err := json.Marshal(claims)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(data, &merged); err != nil {
+			return nil, err
+		}
+	}
+
+	for k, v := range c.extra {
+		merged[k] = v
+	}
+
+	return json.Marshal(merged)
+}
+
+// ClaimsParser parses tokens into typed claims, it is implemented by Service and Verifier.
+type ClaimsParser interface {
+	ParseClaims(token string, claims Claims, opts ...ParseOption) error
+}
+
+// Parse parses the token into a new value of the claims type, e.g.
+// Parse[UserClaims](verifier, token) for a struct embedding RegisteredClaims.
+func Parse[T any, P interface {
+	*T
+	Claims
+}](parser ClaimsParser, token string, opts ...ParseOption) (*T, error) {
+	claims := P(new(T))
+
+	if err := parser.ParseClaims(token, claims, opts...); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
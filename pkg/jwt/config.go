@@ -1,16 +1,38 @@
 package jwt
 
-import "time"
+import (
+	"crypto"
+	"net/http"
+	"time"
+)
+
+// DefaultKeyID is the `kid` of the key built from SetSecret or SetPrivateKey
+// when no key ring is configured.
+const DefaultKeyID = "default"
 
 type Config struct {
 	secret           string
+	algorithm        Algorithm
+	privateKey       crypto.Signer
+	publicKey        crypto.PublicKey
+	keyID            string
+	keyRing          *KeyRing
+	encryptionKey    *EncryptionKey
 	issuer           string
+	audience         []string
 	tokenExpiresIn   time.Duration
 	sessionExpiresIn time.Duration
+	leeway           time.Duration
+	maxAge           time.Duration
+	notBefore        time.Duration
+	issueNotBefore   bool
 }
 
 func NewConfig() *Config {
-	return &Config{}
+	return &Config{
+		algorithm: HS512,
+		keyID:     DefaultKeyID,
+	}
 }
 
 func (c *Config) SetSecret(secret string) *Config {
@@ -18,11 +40,60 @@ func (c *Config) SetSecret(secret string) *Config {
 	return c
 }
 
+// SetAlgorithm selects the signing algorithm, HS512 is used by default.
+func (c *Config) SetAlgorithm(algorithm Algorithm) *Config {
+	c.algorithm = algorithm
+	return c
+}
+
+// SetPrivateKey sets the key used to sign tokens with an asymmetric algorithm.
+// Its public part is used for verification unless SetPublicKey is called.
+func (c *Config) SetPrivateKey(key crypto.Signer) *Config {
+	c.privateKey = key
+	if key != nil && c.publicKey == nil {
+		c.publicKey = key.Public()
+	}
+	return c
+}
+
+// SetPublicKey sets the key used to verify tokens with an asymmetric algorithm.
+// A config with only a public key can parse tokens but not issue them.
+func (c *Config) SetPublicKey(key crypto.PublicKey) *Config {
+	c.publicKey = key
+	return c
+}
+
+// SetKeyID overrides the `kid` of the single configured key.
+func (c *Config) SetKeyID(id string) *Config {
+	c.keyID = id
+	return c
+}
+
+// SetKeyRing replaces the single configured key with a ring of rotating keys,
+// secret, algorithm and keys set on the config are ignored in that case.
+func (c *Config) SetKeyRing(ring *KeyRing) *Config {
+	c.keyRing = ring
+	return c
+}
+
+// SetEncryptionKey makes issued tokens signed and then encrypted as JWE.
+// Parsing decrypts encrypted tokens and still accepts tokens that are only signed.
+func (c *Config) SetEncryptionKey(key *EncryptionKey) *Config {
+	c.encryptionKey = key
+	return c
+}
+
 func (c *Config) SetIssuer(issuer string) *Config {
 	c.issuer = issuer
 	return c
 }
 
+// SetAudience sets the default `aud` of issued tokens, WithAudience overrides it per token.
+func (c *Config) SetAudience(audience ...string) *Config {
+	c.audience = audience
+	return c
+}
+
 func (c *Config) SetSessionExpiresIn(sessionExpiresIn time.Duration) *Config {
 	c.sessionExpiresIn = sessionExpiresIn
 	return c
@@ -32,3 +103,114 @@ func (c *Config) SetTokenExpiresIn(expiresIn time.Duration) *Config {
 	c.tokenExpiresIn = expiresIn
 	return c
 }
+
+// SetLeeway tolerates clock skew between the issuer and the parser when
+// `exp`, `nbf` and `iat` are checked.
+func (c *Config) SetLeeway(leeway time.Duration) *Config {
+	c.leeway = leeway
+	return c
+}
+
+// SetMaxAge rejects parsed tokens issued longer ago than maxAge, whatever their `exp` is.
+func (c *Config) SetMaxAge(maxAge time.Duration) *Config {
+	c.maxAge = maxAge
+	return c
+}
+
+// SetNotBefore writes `nbf` into issued tokens, delay postpones the start of their validity.
+func (c *Config) SetNotBefore(delay time.Duration) *Config {
+	c.notBefore = delay
+	c.issueNotBefore = true
+	return c
+}
+
+type VerifierConfig struct {
+	jwksURL            string
+	jwksFile           string
+	issuer             string
+	encryptionKey      *EncryptionKey
+	audience           []string
+	leeway             time.Duration
+	maxAge             time.Duration
+	httpClient         *http.Client
+	fetchTimeout       time.Duration
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+}
+
+func NewVerifierConfig() *VerifierConfig {
+	return &VerifierConfig{
+		httpClient:         &http.Client{Timeout: 10 * time.Second},
+		fetchTimeout:       5 * time.Second,
+		refreshInterval:    time.Hour,
+		minRefreshInterval: time.Minute,
+	}
+}
+
+// SetJWKSURL sets the URL of the issuer's key set, e.g. https://auth/.well-known/jwks.json.
+func (c *VerifierConfig) SetJWKSURL(url string) *VerifierConfig {
+	c.jwksURL = url
+	return c
+}
+
+// SetJWKSFile sets a local key set file which is used instead of the URL.
+func (c *VerifierConfig) SetJWKSFile(path string) *VerifierConfig {
+	c.jwksFile = path
+	return c
+}
+
+// SetEncryptionKey sets the key encrypted tokens are decrypted with, it has to
+// hold the secret or the private key since JWKS only carries signing keys.
+func (c *VerifierConfig) SetEncryptionKey(key *EncryptionKey) *VerifierConfig {
+	c.encryptionKey = key
+	return c
+}
+
+func (c *VerifierConfig) SetIssuer(issuer string) *VerifierConfig {
+	c.issuer = issuer
+	return c
+}
+
+// SetAudience requires every parsed token to be issued for one of the audiences,
+// usually the identifier of the resource server itself.
+func (c *VerifierConfig) SetAudience(audience ...string) *VerifierConfig {
+	c.audience = audience
+	return c
+}
+
+// SetLeeway tolerates clock skew between the issuer and the verifier when
+// `exp`, `nbf` and `iat` are checked.
+func (c *VerifierConfig) SetLeeway(leeway time.Duration) *VerifierConfig {
+	c.leeway = leeway
+	return c
+}
+
+// SetMaxAge rejects tokens issued longer ago than maxAge, whatever their `exp` is.
+func (c *VerifierConfig) SetMaxAge(maxAge time.Duration) *VerifierConfig {
+	c.maxAge = maxAge
+	return c
+}
+
+func (c *VerifierConfig) SetHTTPClient(client *http.Client) *VerifierConfig {
+	c.httpClient = client
+	return c
+}
+
+// SetFetchTimeout bounds a single key set request, callers waiting for an
+// unknown `kid` are released with an error once it passes.
+func (c *VerifierConfig) SetFetchTimeout(timeout time.Duration) *VerifierConfig {
+	c.fetchTimeout = timeout
+	return c
+}
+
+// SetRefreshInterval sets how long fetched keys are cached before the key set is reloaded.
+func (c *VerifierConfig) SetRefreshInterval(interval time.Duration) *VerifierConfig {
+	c.refreshInterval = interval
+	return c
+}
+
+// SetMinRefreshInterval limits how often tokens with an unknown `kid` may trigger a reload.
+func (c *VerifierConfig) SetMinRefreshInterval(interval time.Duration) *VerifierConfig {
+	c.minRefreshInterval = interval
+	return c
+}
@@ -0,0 +1,139 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"github.com/go-jose/go-jose/v4"
+	"strings"
+)
+
+// KeyManagement is the JWE `alg`, the way the content encryption key is agreed on.
+type KeyManagement string
+
+const (
+	// Direct uses the shared secret itself as the content encryption key.
+	Direct     KeyManagement = "dir"
+	RSAOAEP    KeyManagement = "RSA-OAEP"
+	RSAOAEP256 KeyManagement = "RSA-OAEP-256"
+)
+
+// A256GCM is the only supported content encryption, the JWE `enc`.
+const A256GCM = "A256GCM"
+
+const (
+	// cekSize is the key size of A256GCM, the size of a Direct secret.
+	cekSize = 32
+	// minRSAKeyBits is the smallest RSA key accepted, RFC 7518 section 4.2.
+	minRSAKeyBits = 2048
+)
+
+// EncryptionKey encrypts signed tokens into JWE, so their claims cannot be read
+// by the browser holding the token. Direct keys use a 32 byte Secret, RSA keys
+// encrypt with PublicKey, or the public part of PrivateKey, and need PrivateKey to decrypt.
+type EncryptionKey struct {
+	ID         string
+	Algorithm  KeyManagement
+	Secret     []byte
+	PrivateKey *rsa.PrivateKey
+	PublicKey  *rsa.PublicKey
+}
+
+// Validate checks that the algorithm is supported and the key fits it, so a
+// misconfigured key fails at startup rather than on the first token.
+func (k *EncryptionKey) Validate() error {
+	switch k.Algorithm {
+	case Direct:
+		if len(k.Secret) != cekSize {
+			return fmt.Errorf("%w: %s needs a %d byte secret", ErrInvalidKey, Direct, cekSize)
+		}
+	case RSAOAEP, RSAOAEP256:
+		publicKey := k.publicKey()
+		if publicKey == nil {
+			return fmt.Errorf("%w: %s needs an RSA key", ErrInvalidKey, k.Algorithm)
+		}
+
+		if publicKey.N.BitLen() < minRSAKeyBits {
+			return fmt.Errorf("%w: RSA key must have at least %d bits", ErrInvalidKey, minRSAKeyBits)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedEncryption, k.Algorithm)
+	}
+
+	return nil
+}
+
+// isEncrypted tells a JWE in compact form, which has five parts, from a JWS with three.
+func isEncrypted(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// encrypt wraps the signed token into a nested JWT, RFC 7519 section 5.2.
+func (k *EncryptionKey) encrypt(signed string) (string, error) {
+	if err := k.Validate(); err != nil {
+		return "", err
+	}
+
+	var key interface{} = k.Secret
+	if k.Algorithm != Direct {
+		key = k.publicKey()
+	}
+
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{
+		Algorithm: jose.KeyAlgorithm(k.Algorithm),
+		Key:       key,
+		KeyID:     k.ID,
+	}, (&jose.EncrypterOptions{}).WithContentType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, err := encrypter.Encrypt([]byte(signed))
+	if err != nil {
+		return "", err
+	}
+
+	return encrypted.CompactSerialize()
+}
+
+// decrypt returns the signed token nested into the JWE.
+func (k *EncryptionKey) decrypt(token string) (string, error) {
+	encrypted, err := jose.ParseEncryptedCompact(token,
+		[]jose.KeyAlgorithm{jose.DIRECT, jose.RSA_OAEP, jose.RSA_OAEP_256},
+		[]jose.ContentEncryption{jose.A256GCM})
+	if err != nil {
+		return "", ErrTokenMalformed
+	}
+
+	if KeyManagement(encrypted.Header.Algorithm) != k.Algorithm {
+		return "", ErrUnsupportedEncryption
+	}
+
+	if encrypted.Header.KeyID != "" && k.ID != "" && encrypted.Header.KeyID != k.ID {
+		return "", ErrKeyNotFound
+	}
+
+	var key interface{} = k.Secret
+	if k.Algorithm != Direct {
+		if k.PrivateKey == nil {
+			return "", ErrInvalidKey
+		}
+
+		key = k.PrivateKey
+	}
+
+	signed, err := encrypted.Decrypt(key)
+	if err != nil {
+		return "", ErrTokenDecryption
+	}
+
+	return string(signed), nil
+}
+
+// publicKey returns the key tokens are encrypted with for RSA key management.
+func (k *EncryptionKey) publicKey() *rsa.PublicKey {
+	if k.PublicKey == nil && k.PrivateKey != nil {
+		return &k.PrivateKey.PublicKey
+	}
+
+	return k.PublicKey
+}
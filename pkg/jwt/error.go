@@ -20,7 +20,15 @@ var (
 	ErrTokenInvalidSubject       = errors.New("token has invalid subject")
 	ErrTokenNotValidYet          = errors.New("token is not valid yet")
 	ErrTokenInvalidId            = errors.New("token has invalid id")
+	ErrTokenMaxAgeExceeded       = errors.New("token exceeds max age")
 	ErrInvalidType               = errors.New("invalid type for claim")
+	ErrUnsupportedAlgorithm      = errors.New("unsupported signing algorithm")
+	ErrInvalidKeyID              = errors.New("invalid key id")
+	ErrDuplicateKeyID            = errors.New("duplicate key id")
+	ErrKeyNotFound               = errors.New("key not found")
+	ErrKeySetUnavailable         = errors.New("key set unavailable")
+	ErrUnsupportedEncryption     = errors.New("unsupported token encryption")
+	ErrTokenDecryption           = errors.New("token decryption failed")
 )
 
 var errorsMap = map[error]error{
@@ -42,7 +50,8 @@ var errorsMap = map[error]error{
 }
 
 func ErrIsTiming(err error) bool {
-	if errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenNotValidYet) {
+	if errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenNotValidYet) ||
+		errors.Is(err, ErrTokenUsedBeforeIssued) || errors.Is(err, ErrTokenMaxAgeExceeded) {
 		return true
 	}
 
@@ -0,0 +1,198 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JSONWebKey is a public verification key in RFC 7517 format.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+const p256CoordinateSize = 32
+
+func NewJSONWebKey(id string, alg Algorithm, key crypto.PublicKey) (JSONWebKey, error) {
+	if err := checkPublicKey(alg, key); err != nil {
+		return JSONWebKey{}, err
+	}
+
+	jwk := JSONWebKey{
+		KeyID:     id,
+		Use:       "sig",
+		Algorithm: string(alg),
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeSegment(k.N.Bytes())
+		jwk.E = encodeSegment(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.KeyType = "EC"
+		jwk.Curve = "P-256"
+		jwk.X = encodeSegment(k.X.FillBytes(make([]byte, p256CoordinateSize)))
+		jwk.Y = encodeSegment(k.Y.FillBytes(make([]byte, p256CoordinateSize)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encodeSegment(k)
+	}
+
+	return jwk, nil
+}
+
+// Key converts the JWK into a verification key. The algorithm is derived
+// from the key type when the `alg` member is absent.
+func (k JSONWebKey) Key() (*Key, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, ErrInvalidKeyType
+	}
+
+	var (
+		alg Algorithm
+		pub crypto.PublicKey
+		err error
+	)
+
+	switch k.KeyType {
+	case "RSA":
+		alg = RS256
+		pub, err = k.rsaPublicKey()
+	case "EC":
+		alg = ES256
+		pub, err = k.ecdsaPublicKey()
+	case "OKP":
+		alg = EdDSA
+		pub, err = k.ed25519PublicKey()
+	default:
+		return nil, ErrInvalidKeyType
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if k.Algorithm != "" {
+		alg = Algorithm(k.Algorithm)
+	}
+
+	if err = checkPublicKey(alg, pub); err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		ID:        k.KeyID,
+		Algorithm: alg,
+		PublicKey: pub,
+		State:     KeyVerifyOnly,
+	}, nil
+}
+
+func (k JSONWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeSegment(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, ErrInvalidKey
+	}
+
+	e, err := decodeSegment(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, ErrInvalidKey
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (k JSONWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	if k.Curve != "P-256" {
+		return nil, ErrInvalidKeyType
+	}
+
+	x, err := decodeSegment(k.X)
+	if err != nil || len(x) != p256CoordinateSize {
+		return nil, ErrInvalidKey
+	}
+
+	y, err := decodeSegment(k.Y)
+	if err != nil || len(y) != p256CoordinateSize {
+		return nil, ErrInvalidKey
+	}
+
+	// ecdh validates that the point is on the curve
+	point := append(append([]byte{4}, x...), y...)
+	if _, err = ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+func (k JSONWebKey) ed25519PublicKey() (ed25519.PublicKey, error) {
+	if k.Curve != "Ed25519" {
+		return nil, ErrInvalidKeyType
+	}
+
+	x, err := decodeSegment(k.X)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
+// JWKS returns the public keys of the ring that can verify tokens. Symmetric
+// keys are never published. Keys pending activation are included, so that
+// resource servers already know them when they start signing.
+func (r *KeyRing) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{
+		Keys: make([]JSONWebKey, 0),
+	}
+
+	for _, key := range r.Keys() {
+		if !key.Algorithm.IsAsymmetric() {
+			continue
+		}
+
+		jwk, err := NewJSONWebKey(key.ID, key.Algorithm, key.PublicKey)
+		if err != nil {
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -1,9 +1,14 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -61,6 +66,30 @@ func TestService_ParseTokenClaims(t *testing.T) {
 	assert.Equal(t, "val2", claims["key2"])
 }
 
+func TestService_ParseTokenClaimsWithoutValidation(t *testing.T) {
+	svc := NewService(testConf())
+
+	expiredClaims := func() *RegisteredClaims {
+		return &RegisteredClaims{Subject: subject, ExpiresAt: NewNumericDate(time.Now().Add(-time.Hour))}
+	}
+
+	expired, err := svc.IssueClaims(expiredClaims())
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaims(expired)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+
+	claims, err := svc.ParseTokenClaimsWithoutValidation(expired)
+	assert.NoError(t, err)
+	assert.Equal(t, subject, claims["sub"])
+
+	other, err := NewService(testConf().SetIssuer("other")).IssueClaims(expiredClaims())
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaimsWithoutValidation(other)
+	assert.ErrorIs(t, err, ErrTokenInvalidIssuer, "the issuer is checked even without validation")
+}
+
 func TestService_IssueToken(t *testing.T) {
 	svc := NewService(testConf())
 	tokenRegexp := regexp.MustCompile(`.+\..+\..+`)
@@ -70,3 +99,592 @@ func TestService_IssueToken(t *testing.T) {
 
 	assert.True(t, tokenRegexp.MatchString(token))
 }
+
+func TestService_IssueTokenExpiresIn(t *testing.T) {
+	svc := NewService(testConf())
+
+	token, err := svc.IssueToken(subject, nil, WithExpiresIn(time.Hour))
+	assert.NoError(t, err)
+
+	claims, err := svc.ParseTokenClaims(token)
+	assert.NoError(t, err)
+
+	exp, _ := strconv.ParseInt(claims["exp"], 10, 64)
+	iat, _ := strconv.ParseInt(claims["iat"], 10, 64)
+	assert.Equal(t, int64(time.Hour.Seconds()), exp-iat)
+}
+
+func TestService_IssueTokenAudienceAndClaims(t *testing.T) {
+	svc := NewService(testConf())
+
+	token, err := svc.IssueToken(subject, map[string]string{"nonce": "n-0S6_WzA2Mj"},
+		WithAudience("client"), WithClaim("auth_time", int64(1700000000)))
+	assert.NoError(t, err)
+
+	claims, err := svc.ParseTokenClaims(token)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "client", claims["aud"])
+	assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+	assert.Equal(t, "1700000000", claims["auth_time"])
+}
+
+func TestService_Audience(t *testing.T) {
+	svc := NewService(testConf().SetAudience("orders", "billing"))
+
+	token, err := svc.IssueToken(subject, nil)
+	assert.NoError(t, err)
+
+	claims, err := svc.ParseTokenClaims(token)
+	assert.NoError(t, err)
+	assert.Equal(t, `["orders","billing"]`, claims["aud"])
+
+	_, err = svc.ParseTokenClaims(token, WithExpectedAudience("billing"))
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenSubject(token, false, WithExpectedAudience("payments"))
+	assert.ErrorIs(t, err, ErrTokenInvalidAudience)
+
+	token, err = svc.IssueToken(subject, nil, WithAudience("payments"))
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaims(token, WithExpectedAudience("orders"))
+	assert.ErrorIs(t, err, ErrTokenInvalidAudience, "per token audience replaces the configured one")
+
+	sub, err := svc.ParseTokenSubject(token, false, WithExpectedAudience("orders", "payments"))
+	assert.NoError(t, err)
+	assert.Equal(t, subject, sub)
+
+	token, err = NewService(testConf()).IssueToken(subject, nil)
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaims(token, WithExpectedAudience("orders"))
+	assert.ErrorIs(t, err, ErrTokenInvalidAudience, "token without audience")
+}
+
+type profileClaims struct {
+	RegisteredClaims
+	Roles   []string `json:"roles"`
+	Profile struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	} `json:"profile"`
+	AuthTime int64 `json:"auth_time"`
+}
+
+func TestService_TypedClaims(t *testing.T) {
+	svc := NewService(testConf().SetAudience("orders"))
+
+	claims := &profileClaims{
+		RegisteredClaims: RegisteredClaims{Subject: subject, ID: "token-1"},
+		Roles:            []string{"admin", "billing"},
+		AuthTime:         1700000000,
+	}
+	claims.Profile.Name = "Ada"
+	claims.Profile.Age = 36
+
+	token, err := svc.IssueClaims(claims, WithClaim("tenant", "acme"))
+	assert.NoError(t, err)
+
+	parsed, err := Parse[profileClaims](svc, token, WithExpectedAudience("orders"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, subject, parsed.Subject)
+	assert.Equal(t, issuer, parsed.Issuer)
+	assert.Equal(t, "token-1", parsed.ID)
+	assert.Equal(t, Audience{"orders"}, parsed.Audience)
+	assert.Equal(t, []string{"admin", "billing"}, parsed.Roles)
+	assert.Equal(t, "Ada", parsed.Profile.Name)
+	assert.Equal(t, 36, parsed.Profile.Age)
+	assert.Equal(t, int64(1700000000), parsed.AuthTime)
+	assert.Equal(t, expiresIn, parsed.ExpiresAt.Sub(parsed.IssuedAt.Time))
+
+	flat, err := svc.ParseTokenClaims(token)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "orders", flat["aud"])
+	assert.Equal(t, "acme", flat["tenant"])
+	assert.Equal(t, `["admin","billing"]`, flat["roles"])
+
+	t.Run("Map API token", func(t *testing.T) {
+		token, err := svc.IssueToken(subject, map[string]string{"ip": "127.0.0.1"},
+			WithAudience("orders", "billing"))
+		assert.NoError(t, err)
+
+		var claims struct {
+			RegisteredClaims
+			Ip string `json:"ip"`
+		}
+
+		assert.NoError(t, svc.ParseClaims(token, &claims))
+		assert.Equal(t, subject, claims.Subject)
+		assert.Equal(t, "127.0.0.1", claims.Ip)
+		assert.Equal(t, Audience{"orders", "billing"}, claims.Audience)
+	})
+
+	t.Run("Invalid claims", func(t *testing.T) {
+		_, err := Parse[profileClaims](svc, token, WithExpectedAudience("billing"))
+		assert.ErrorIs(t, err, ErrTokenInvalidAudience)
+
+		other := NewService(NewConfig().SetIssuer(issuer).SetSecret("other").SetTokenExpiresIn(expiresIn))
+
+		_, err = Parse[profileClaims](other, token)
+		assert.ErrorIs(t, err, ErrTokenSignatureInvalid)
+	})
+}
+
+func TestService_IssueClaimsTwice(t *testing.T) {
+	svc := NewService(testConf())
+
+	claims := &profileClaims{
+		RegisteredClaims: RegisteredClaims{Subject: subject},
+		Roles:            []string{"admin"},
+	}
+
+	first, err := svc.IssueClaims(claims, WithAudience("orders"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, RegisteredClaims{Subject: subject}, claims.RegisteredClaims, "the template must not be changed")
+
+	second, err := svc.IssueClaims(claims, WithExpiresIn(time.Hour))
+	assert.NoError(t, err)
+
+	parsed, err := Parse[profileClaims](svc, first)
+	assert.NoError(t, err)
+	assert.Equal(t, Audience{"orders"}, parsed.Audience)
+	assert.Equal(t, expiresIn, parsed.ExpiresAt.Sub(parsed.IssuedAt.Time))
+
+	parsed, err = Parse[profileClaims](svc, second)
+	assert.NoError(t, err)
+	assert.Empty(t, parsed.Audience, "the audience of the first token must not be kept")
+	assert.Equal(t, time.Hour, parsed.ExpiresAt.Sub(parsed.IssuedAt.Time), "the expiry of the first token must not be kept")
+	assert.Equal(t, []string{"admin"}, parsed.Roles)
+}
+
+func TestService_Leeway(t *testing.T) {
+	svc := NewService(testConf())
+	skewed := NewService(testConf().SetLeeway(5 * time.Second))
+
+	expired, err := svc.IssueClaims(&RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: NewNumericDate(time.Now().Add(-2 * time.Second)),
+	})
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaims(expired)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+
+	_, err = skewed.ParseTokenClaims(expired)
+	assert.NoError(t, err)
+
+	notYetValid, err := svc.IssueToken(subject, nil, WithNotBefore(time.Now().Add(2*time.Second)))
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenSubject(notYetValid, false)
+	assert.ErrorIs(t, err, ErrTokenNotValidYet)
+	assert.True(t, ErrIsTiming(err))
+
+	_, err = skewed.ParseTokenSubject(notYetValid, false)
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenSubject(notYetValid, false, WithLeeway(5*time.Second))
+	assert.NoError(t, err)
+}
+
+func TestService_NotBefore(t *testing.T) {
+	svc := NewService(testConf().SetNotBefore(0))
+
+	token, err := svc.IssueToken(subject, nil)
+	assert.NoError(t, err)
+
+	claims, err := svc.ParseTokenClaims(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims["iat"], claims["nbf"])
+
+	svc = NewService(testConf().SetNotBefore(time.Hour))
+
+	token, err = svc.IssueToken(subject, nil)
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaims(token)
+	assert.ErrorIs(t, err, ErrTokenNotValidYet)
+
+	token, err = NewService(testConf()).IssueToken(subject, nil)
+	assert.NoError(t, err)
+
+	claims, err = svc.ParseTokenClaims(token)
+	assert.NoError(t, err)
+	assert.NotContains(t, claims, "nbf")
+}
+
+func TestService_MaxAge(t *testing.T) {
+	svc := NewService(testConf().SetMaxAge(time.Minute))
+
+	token, err := svc.IssueToken(subject, nil)
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaims(token)
+	assert.NoError(t, err)
+
+	old, err := svc.IssueClaims(&RegisteredClaims{
+		Subject:  subject,
+		IssuedAt: NewNumericDate(time.Now().Add(-2 * time.Minute)),
+	}, WithExpiresIn(time.Hour))
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaims(old)
+	assert.ErrorIs(t, err, ErrTokenMaxAgeExceeded)
+	assert.True(t, ErrIsTiming(err))
+
+	_, err = svc.ParseTokenClaims(old, WithMaxAge(time.Hour))
+	assert.NoError(t, err, "call option overrides the configured max age")
+
+	_, err = NewService(testConf()).ParseTokenClaims(old, WithMaxAge(time.Minute), WithLeeway(2*time.Minute))
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaimsWithoutValidation(old)
+	assert.NoError(t, err)
+}
+
+func TestService_ParseNestedClaims(t *testing.T) {
+	svc := NewService(testConf())
+
+	token, err := svc.IssueToken(subject, nil, WithAudience("orders", "billing"),
+		WithClaim("act", map[string]interface{}{"sub": "gateway"}))
+	assert.NoError(t, err)
+
+	claims, err := svc.ParseTokenClaims(token)
+	assert.NoError(t, err)
+
+	assert.JSONEq(t, `["orders","billing"]`, claims["aud"])
+	assert.JSONEq(t, `{"sub":"gateway"}`, claims["act"])
+}
+
+func TestService_AsymmetricAlgorithms(t *testing.T) {
+	for _, alg := range []Algorithm{RS256, ES256, EdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			key, err := GenerateKey(alg)
+			if err != nil {
+				assert.Fail(t, "error on key generation")
+				return
+			}
+
+			issuerSvc := NewService(NewConfig().
+				SetIssuer(issuer).
+				SetAlgorithm(alg).
+				SetPrivateKey(key).
+				SetTokenExpiresIn(expiresIn))
+
+			verifierSvc := NewService(NewConfig().
+				SetIssuer(issuer).
+				SetAlgorithm(alg).
+				SetPublicKey(key.Public()))
+
+			token, err := issuerSvc.IssueToken(subject, map[string]string{"key1": "val1"})
+			assert.NoError(t, err)
+
+			claims, err := verifierSvc.ParseTokenClaims(token)
+			assert.NoError(t, err)
+			assert.Equal(t, subject, claims["sub"])
+			assert.Equal(t, "val1", claims["key1"])
+
+			_, err = verifierSvc.IssueToken(subject, nil)
+			assert.ErrorIs(t, err, ErrInvalidKey)
+
+			otherKey, _ := GenerateKey(alg)
+			otherSvc := NewService(NewConfig().
+				SetIssuer(issuer).
+				SetAlgorithm(alg).
+				SetPublicKey(otherKey.Public()))
+
+			_, err = otherSvc.ParseTokenClaims(token)
+			assert.ErrorIs(t, err, ErrTokenSignatureInvalid)
+		})
+	}
+}
+
+func TestService_AlgorithmMismatch(t *testing.T) {
+	hmacToken, err := NewService(testConf()).IssueToken(subject, nil)
+	if err != nil {
+		assert.Fail(t, "error on token issue")
+		return
+	}
+
+	key, _ := GenerateKey(RS256)
+	svc := NewService(NewConfig().
+		SetIssuer(issuer).
+		SetAlgorithm(RS256).
+		SetPublicKey(key.Public()))
+
+	_, err = svc.ParseTokenClaims(hmacToken)
+	assert.ErrorIs(t, err, ErrTokenSignatureInvalid)
+
+	ecKey, _ := GenerateKey(ES256)
+	svc = NewService(NewConfig().
+		SetIssuer(issuer).
+		SetAlgorithm(RS256).
+		SetPrivateKey(ecKey))
+
+	_, err = svc.IssueToken(subject, nil)
+	assert.ErrorIs(t, err, ErrInvalidKeyType)
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	for _, alg := range []Algorithm{RS256, ES256, EdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			key, _ := GenerateKey(alg)
+
+			encoded, err := MarshalPrivateKeyPEM(key)
+			assert.NoError(t, err)
+
+			parsed, err := ParsePrivateKeyPEM(encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, key.Public(), parsed.Public())
+
+			encodedPublic, err := MarshalPublicKeyPEM(key.Public())
+			assert.NoError(t, err)
+
+			parsedPublic, err := ParsePublicKeyPEM(encodedPublic)
+			assert.NoError(t, err)
+			assert.Equal(t, key.Public(), parsedPublic)
+		})
+	}
+
+	_, err := ParsePrivateKeyPEM([]byte("not a key"))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestService_KeyRotation(t *testing.T) {
+	oldKey, _ := GenerateKey(ES256)
+	newKey, _ := GenerateKey(EdDSA)
+
+	ring, err := NewKeyRing(&Key{
+		ID:         "old",
+		Algorithm:  ES256,
+		PrivateKey: oldKey,
+		State:      KeyActive,
+	})
+	if err != nil {
+		assert.Fail(t, "error on key ring creation")
+		return
+	}
+
+	svc := NewService(NewConfig().
+		SetIssuer(issuer).
+		SetKeyRing(ring).
+		SetTokenExpiresIn(expiresIn))
+
+	oldToken, err := svc.IssueToken(subject, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ring.Add(&Key{
+		ID:          "new",
+		Algorithm:   EdDSA,
+		PrivateKey:  newKey,
+		State:       KeyActive,
+		ActivatesAt: time.Now().Add(time.Hour),
+	}))
+
+	token, err := svc.IssueToken(subject, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", tokenKID(t, token), "pending key must not sign before activation")
+
+	ring.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	assert.NoError(t, ring.SetState("old", KeyVerifyOnly))
+
+	newToken, err := svc.IssueToken(subject, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", tokenKID(t, newToken))
+
+	ring.now = time.Now
+
+	_, err = svc.ParseTokenClaims(oldToken)
+	assert.NoError(t, err)
+
+	_, err = svc.ParseTokenClaims(newToken)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ring.SetState("old", KeyRetired))
+
+	_, err = svc.ParseTokenClaims(oldToken)
+	assert.ErrorIs(t, err, ErrTokenUnverifiable)
+
+	assert.ErrorIs(t, ring.Add(&Key{ID: "new", Algorithm: HS512, Secret: []byte(secret)}), ErrDuplicateKeyID)
+	assert.ErrorIs(t, ring.Add(&Key{Algorithm: HS512, Secret: []byte(secret)}), ErrInvalidKeyID)
+}
+
+func TestService_UnknownKeyID(t *testing.T) {
+	token, err := NewService(testConf().SetKeyID("first")).IssueToken(subject, nil)
+	if err != nil {
+		assert.Fail(t, "error on token issue")
+		return
+	}
+
+	_, err = NewService(testConf().SetKeyID("second")).ParseTokenClaims(token)
+	assert.ErrorIs(t, err, ErrTokenUnverifiable)
+}
+
+func tokenKID(t *testing.T, token string) string {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		assert.Fail(t, "error on token parse")
+		return ""
+	}
+
+	kid, _ := parsed.Header["kid"].(string)
+
+	return kid
+}
+
+func TestService_JWKS(t *testing.T) {
+	rsaKey, _ := GenerateKey(RS256)
+	ecKey, _ := GenerateKey(ES256)
+	edKey, _ := GenerateKey(EdDSA)
+
+	ring, err := NewKeyRing(
+		&Key{ID: "rsa", Algorithm: RS256, PrivateKey: rsaKey},
+		&Key{ID: "ec", Algorithm: ES256, PrivateKey: ecKey, State: KeyVerifyOnly},
+		&Key{ID: "ed", Algorithm: EdDSA, PrivateKey: edKey, State: KeyRetired},
+		&Key{ID: "hmac", Algorithm: HS512, Secret: []byte(secret)},
+	)
+	if err != nil {
+		assert.Fail(t, "error on key ring creation")
+		return
+	}
+
+	set := NewService(NewConfig().SetKeyRing(ring)).JWKS()
+
+	if !assert.Len(t, set.Keys, 2) {
+		return
+	}
+
+	assert.Equal(t, "ec", set.Keys[0].KeyID)
+	assert.Equal(t, "EC", set.Keys[0].KeyType)
+	assert.Equal(t, "P-256", set.Keys[0].Curve)
+	assert.Len(t, set.Keys[0].X, 43)
+	assert.Len(t, set.Keys[0].Y, 43)
+
+	assert.Equal(t, "rsa", set.Keys[1].KeyID)
+	assert.Equal(t, "RSA", set.Keys[1].KeyType)
+	assert.Equal(t, "RS256", set.Keys[1].Algorithm)
+	assert.Equal(t, "sig", set.Keys[1].Use)
+	assert.Equal(t, "AQAB", set.Keys[1].E)
+}
+
+func TestService_Encryption(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	keys := map[KeyManagement]*EncryptionKey{
+		Direct:     {ID: "enc", Algorithm: Direct, Secret: []byte("0123456789abcdef0123456789abcdef")},
+		RSAOAEP:    {Algorithm: RSAOAEP, PrivateKey: rsaKey},
+		RSAOAEP256: {Algorithm: RSAOAEP256, PrivateKey: rsaKey},
+	}
+
+	for alg, key := range keys {
+		t.Run(string(alg), func(t *testing.T) {
+			svc := NewService(testConf().SetEncryptionKey(key))
+
+			token, err := svc.IssueToken(subject, map[string]string{"ip": "127.0.0.1"})
+			assert.NoError(t, err)
+
+			assert.Len(t, strings.Split(token, "."), 5)
+			assert.NotContains(t, token, base64.RawURLEncoding.EncodeToString([]byte("127.0.0.1")))
+
+			claims, err := svc.ParseTokenClaims(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "127.0.0.1", claims["ip"])
+
+			sub, err := svc.ParseTokenSubject(token, false)
+			assert.NoError(t, err)
+			assert.Equal(t, subject, sub)
+
+			_, err = NewService(testConf()).ParseTokenClaims(token)
+			assert.ErrorIs(t, err, ErrUnsupportedEncryption, "parser without the key")
+		})
+	}
+
+	svc := NewService(testConf().SetEncryptionKey(keys[Direct]))
+
+	t.Run("Tampered token", func(t *testing.T) {
+		token, err := svc.IssueToken(subject, nil)
+		assert.NoError(t, err)
+
+		parts := strings.Split(token, ".")
+		ciphertext, _ := base64.RawURLEncoding.DecodeString(parts[3])
+		ciphertext[0] ^= 1
+		parts[3] = base64.RawURLEncoding.EncodeToString(ciphertext)
+
+		_, err = svc.ParseTokenClaims(strings.Join(parts, "."))
+		assert.ErrorIs(t, err, ErrTokenDecryption)
+
+		other := NewService(testConf().SetEncryptionKey(&EncryptionKey{
+			ID:        "enc",
+			Algorithm: Direct,
+			Secret:    []byte("fedcba9876543210fedcba9876543210"),
+		}))
+
+		_, err = other.ParseTokenClaims(token)
+		assert.ErrorIs(t, err, ErrTokenDecryption)
+	})
+
+	t.Run("Signed tokens", func(t *testing.T) {
+		token, err := svc.IssueToken(subject, nil, WithoutEncryption())
+		assert.NoError(t, err)
+		assert.Len(t, strings.Split(token, "."), 3)
+
+		_, err = svc.ParseTokenClaims(token)
+		assert.NoError(t, err)
+
+		token, err = NewService(testConf()).IssueToken(subject, nil)
+		assert.NoError(t, err)
+
+		_, err = svc.ParseTokenClaims(token)
+		assert.NoError(t, err)
+	})
+}
+
+func TestEncryptionKey_Validate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	assert.NoError(t, (&EncryptionKey{Algorithm: Direct, Secret: make([]byte, 32)}).Validate())
+	assert.NoError(t, (&EncryptionKey{Algorithm: RSAOAEP256, PublicKey: &rsaKey.PublicKey}).Validate())
+
+	for name, key := range map[string]*EncryptionKey{
+		"Short secret":   {Algorithm: Direct, Secret: make([]byte, 16)},
+		"Missing RSA":    {Algorithm: RSAOAEP},
+		"Weak RSA":       {Algorithm: RSAOAEP256, PrivateKey: weakKey},
+		"Key wrap":       {Algorithm: "A256KW", Secret: make([]byte, 32)},
+		"No algorithm":   {Secret: make([]byte, 32)},
+		"Secret for RSA": {Algorithm: RSAOAEP, Secret: make([]byte, 32)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := key.Validate()
+			assert.Error(t, err)
+
+			_, err = NewService(testConf().SetEncryptionKey(key)).IssueToken(subject, nil)
+			assert.Error(t, err, "an invalid key must not issue tokens")
+		})
+	}
+}
+
+func TestService_EncryptionHeader(t *testing.T) {
+	svc := NewService(testConf().SetEncryptionKey(&EncryptionKey{
+		ID:        "enc",
+		Algorithm: Direct,
+		Secret:    []byte("0123456789abcdef0123456789abcdef"),
+	}))
+
+	token, err := svc.IssueToken(subject, nil)
+	assert.NoError(t, err)
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	assert.NoError(t, err)
+
+	// RFC 7519 section 5.2, cty marks the nested JWT
+	assert.JSONEq(t, `{"alg":"dir","enc":"A256GCM","cty":"JWT","kid":"enc"}`, string(data))
+}
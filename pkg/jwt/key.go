@@ -0,0 +1,145 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Algorithm string
+
+const (
+	HS512 Algorithm = "HS512"
+	RS256 Algorithm = "RS256"
+	ES256 Algorithm = "ES256"
+	EdDSA Algorithm = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+func (a Algorithm) method() (jwt.SigningMethod, error) {
+	switch a {
+	case HS512:
+		return jwt.SigningMethodHS512, nil
+	case RS256:
+		return jwt.SigningMethodRS256, nil
+	case ES256:
+		return jwt.SigningMethodES256, nil
+	case EdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	}
+
+	return nil, ErrUnsupportedAlgorithm
+}
+
+// IsAsymmetric reports whether tokens signed with the algorithm can be verified by the public key alone.
+func (a Algorithm) IsAsymmetric() bool {
+	return a == RS256 || a == ES256 || a == EdDSA
+}
+
+// GenerateKey creates a new private key suitable for the given asymmetric algorithm.
+func GenerateKey(alg Algorithm) (crypto.Signer, error) {
+	switch alg {
+	case RS256:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case ES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+
+	return nil, ErrUnsupportedAlgorithm
+}
+
+// ParsePrivateKeyPEM reads a PKCS#8, PKCS#1 or SEC 1 encoded private key.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, ErrInvalidKeyType
+		}
+
+		return signer, nil
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, ErrInvalidKey
+}
+
+// ParsePublicKeyPEM reads a PKIX or PKCS#1 encoded public key.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, ErrInvalidKey
+}
+
+// MarshalPrivateKeyPEM encodes the key as a PKCS#8 PEM block.
+func MarshalPrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalPublicKeyPEM encodes the key as a PKIX PEM block.
+func MarshalPublicKeyPEM(key crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// checkPublicKey makes sure the key type matches the algorithm, so that
+// a key configured for one algorithm is never used with another.
+func checkPublicKey(alg Algorithm, key crypto.PublicKey) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg == RS256 {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if alg == ES256 && k.Curve == elliptic.P256() {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if alg == EdDSA {
+			return nil
+		}
+	}
+
+	return ErrInvalidKeyType
+}
@@ -0,0 +1,240 @@
+package jwt
+
+import (
+	"crypto"
+	"sort"
+	"sync"
+	"time"
+)
+
+type KeyState string
+
+const (
+	// KeyActive keys sign new tokens once ActivatesAt has passed and verify them.
+	KeyActive KeyState = "active"
+	// KeyVerifyOnly keys only verify tokens that were signed before the rotation.
+	KeyVerifyOnly KeyState = "verify-only"
+	// KeyRetired keys are kept for bookkeeping and neither sign nor verify.
+	KeyRetired KeyState = "retired"
+)
+
+type Key struct {
+	ID          string
+	Algorithm   Algorithm
+	Secret      []byte
+	PrivateKey  crypto.Signer
+	PublicKey   crypto.PublicKey
+	State       KeyState
+	ActivatesAt time.Time
+	RetiresAt   time.Time
+}
+
+// StateAt returns the state of the key at the given moment,
+// taking activation and retirement times into account.
+func (k *Key) StateAt(t time.Time) KeyState {
+	if k.State == KeyRetired || (!k.RetiresAt.IsZero() && !t.Before(k.RetiresAt)) {
+		return KeyRetired
+	}
+
+	if k.State == KeyActive && !t.Before(k.ActivatesAt) {
+		return KeyActive
+	}
+
+	return KeyVerifyOnly
+}
+
+func (k *Key) signingKey() (interface{}, error) {
+	if !k.Algorithm.IsAsymmetric() {
+		if len(k.Secret) == 0 {
+			return nil, ErrInvalidKey
+		}
+
+		return k.Secret, nil
+	}
+
+	if k.PrivateKey == nil {
+		return nil, ErrInvalidKey
+	}
+
+	if err := checkPublicKey(k.Algorithm, k.PrivateKey.Public()); err != nil {
+		return nil, err
+	}
+
+	return k.PrivateKey, nil
+}
+
+func (k *Key) verificationKey() (interface{}, error) {
+	if !k.Algorithm.IsAsymmetric() {
+		if len(k.Secret) == 0 {
+			return nil, ErrInvalidKey
+		}
+
+		return k.Secret, nil
+	}
+
+	if k.PublicKey == nil {
+		return nil, ErrInvalidKey
+	}
+
+	if err := checkPublicKey(k.Algorithm, k.PublicKey); err != nil {
+		return nil, err
+	}
+
+	return k.PublicKey, nil
+}
+
+// KeyRing holds the signing and verification keys of a service. Tokens are
+// signed by the newest active key and carry its ID in the `kid` header, so
+// keys can be rotated while tokens signed by the previous one stay valid.
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys map[string]*Key
+	now  func() time.Time
+}
+
+func NewKeyRing(keys ...*Key) (*KeyRing, error) {
+	r := &KeyRing{
+		keys: make(map[string]*Key),
+		now:  time.Now,
+	}
+
+	for _, key := range keys {
+		if err := r.Add(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Add puts a new key into the ring. The public key is derived from
+// the private one when it is not set explicitly.
+func (r *KeyRing) Add(key *Key) error {
+	if key.ID == "" {
+		return ErrInvalidKeyID
+	}
+
+	if _, err := key.Algorithm.method(); err != nil {
+		return err
+	}
+
+	if key.PublicKey == nil && key.PrivateKey != nil {
+		key.PublicKey = key.PrivateKey.Public()
+	}
+
+	if key.State == "" {
+		key.State = KeyActive
+	}
+
+	if _, err := key.verificationKey(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.keys[key.ID]; ok {
+		return ErrDuplicateKeyID
+	}
+
+	r.keys[key.ID] = key
+
+	return nil
+}
+
+// SetState changes the state of the key, e.g. to demote the previous
+// signing key to verify-only or to retire it after tokens have expired.
+func (r *KeyRing) SetState(id string, state KeyState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	key.State = state
+
+	return nil
+}
+
+// Keys returns the keys that can currently verify tokens.
+func (r *KeyRing) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+
+	keys := make([]*Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.StateAt(now) != KeyRetired {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys
+}
+
+// SigningKey returns the most recently activated active key.
+func (r *KeyRing) SigningKey() (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+
+	var signing *Key
+	for _, key := range r.keys {
+		if key.StateAt(now) != KeyActive {
+			continue
+		}
+
+		if signing == nil || key.ActivatesAt.After(signing.ActivatesAt) ||
+			key.ActivatesAt.Equal(signing.ActivatesAt) && key.ID > signing.ID {
+			signing = key
+		}
+	}
+
+	if signing == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	return signing, nil
+}
+
+// VerificationKey returns the key with the given ID if it is not retired.
+func (r *KeyRing) VerificationKey(id string) (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[id]
+	if !ok || key.StateAt(r.now()) == KeyRetired {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (r *KeyRing) algorithms() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[Algorithm]bool)
+	algorithms := make([]string, 0, len(r.keys))
+
+	for _, key := range r.keys {
+		if seen[key.Algorithm] {
+			continue
+		}
+
+		seen[key.Algorithm] = true
+		algorithms = append(algorithms, string(key.Algorithm))
+	}
+
+	sort.Strings(algorithms)
+
+	return algorithms
+}
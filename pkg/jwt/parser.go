@@ -0,0 +1,216 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// keySource resolves the key a token is verified with, it is shared
+// by the issuing Service and the JWKS backed Verifier.
+type keySource interface {
+	verificationKey(t *jwt.Token) (interface{}, error)
+	validMethods() []string
+	decryptionKey() *EncryptionKey
+}
+
+// ParseOption adds a check to a single parsed token.
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	audience []string
+	leeway   time.Duration
+	maxAge   time.Duration
+}
+
+// WithExpectedAudience rejects tokens whose `aud` claim contains none of the audiences.
+func WithExpectedAudience(audience ...string) ParseOption {
+	return func(o *parseOptions) {
+		o.audience = audience
+	}
+}
+
+// WithLeeway tolerates clock skew when `exp`, `nbf` and `iat` are checked.
+func WithLeeway(leeway time.Duration) ParseOption {
+	return func(o *parseOptions) {
+		o.leeway = leeway
+	}
+}
+
+// WithMaxAge rejects tokens issued longer ago than maxAge, e.g. to force
+// a recent login. Tokens without `iat` are rejected as well.
+func WithMaxAge(maxAge time.Duration) ParseOption {
+	return func(o *parseOptions) {
+		o.maxAge = maxAge
+	}
+}
+
+func parseTokenSubject(src keySource, token, issuer string, withoutValidation bool, opts []ParseOption) (string, error) {
+	var claims RegisteredClaims
+
+	if err := parseClaims(src, token, issuer, withoutValidation, &claims, opts); err != nil {
+		return "", err
+	}
+
+	return claims.Subject, nil
+}
+
+func parseClaims(src keySource, token, issuer string, withoutValidation bool, claims Claims,
+	opts []ParseOption) error {
+	return mapError(parseToken(src, token, issuer, withoutValidation, claims, opts))
+}
+
+// parseTokenClaims flattens the claims of the token into strings, nested
+// claims are kept as JSON. Use parseClaims to keep the claim types.
+func parseTokenClaims(src keySource, token, issuer string, withoutValidation bool,
+	opts []ParseOption) (map[string]string, error) {
+	originClaims := jwt.MapClaims{}
+
+	err := parseToken(src, token, issuer, withoutValidation, originClaims, opts)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	result := make(map[string]string)
+
+	for key, value := range originClaims {
+		switch v := value.(type) {
+		case string:
+			result[key] = v
+		case float64:
+			result[key] = strconv.Itoa(int(v))
+		case map[string]interface{}, []interface{}:
+			// nested claims such as `act` are kept as JSON
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, ErrInvalidType
+			}
+
+			result[key] = string(encoded)
+		default:
+			result[key] = fmt.Sprint(v)
+		}
+	}
+
+	return result, nil
+}
+
+func parseToken(src keySource, token, issuer string, withoutValidation bool, claims jwt.Claims,
+	opts []ParseOption) error {
+	var options parseOptions
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(src.validMethods()),
+		jwt.WithIssuer(issuer),
+		jwt.WithLeeway(options.leeway),
+	}
+
+	if options.maxAge > 0 {
+		parserOptions = append(parserOptions, jwt.WithIssuedAt())
+	}
+
+	if withoutValidation {
+		parserOptions = append(parserOptions, jwt.WithoutClaimsValidation())
+	}
+
+	parser := jwt.NewParser(parserOptions...)
+
+	if isEncrypted(token) {
+		key := src.decryptionKey()
+		if key == nil {
+			return ErrUnsupportedEncryption
+		}
+
+		signed, err := key.decrypt(token)
+		if err != nil {
+			return err
+		}
+
+		token = signed
+	}
+
+	parsed, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		_, err := t.Claims.GetSubject()
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		return src.verificationKey(t)
+	})
+
+	if parsed == nil || !parsed.Valid {
+		return mapError(err)
+	}
+
+	if withoutValidation {
+		// only the time based claims are skipped, a token of another issuer is never accepted
+		return verifyIssuer(claims, issuer)
+	}
+
+	if err = verifyAudience(claims, options); err != nil {
+		return err
+	}
+
+	return verifyMaxAge(claims, options)
+}
+
+// verifyIssuer checks the `iss` claim the parser skips along with the other claims
+// when the token is parsed without validation.
+func verifyIssuer(claims jwt.Claims, issuer string) error {
+	if issuer == "" {
+		return nil
+	}
+
+	iss, err := claims.GetIssuer()
+	if err != nil || iss != issuer {
+		return ErrTokenInvalidIssuer
+	}
+
+	return nil
+}
+
+// verifyMaxAge checks the age of the token by its `iat` claim, allowing for the leeway.
+func verifyMaxAge(claims jwt.Claims, options parseOptions) error {
+	if options.maxAge <= 0 {
+		return nil
+	}
+
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return ErrTokenRequiredClaimMissing
+	}
+
+	if time.Since(issuedAt.Time) > options.maxAge+options.leeway {
+		return ErrTokenMaxAgeExceeded
+	}
+
+	return nil
+}
+
+// verifyAudience checks the `aud` claim against the expected audiences,
+// a token without the claim is rejected as soon as any audience is expected.
+func verifyAudience(claims jwt.Claims, options parseOptions) error {
+	if len(options.audience) == 0 {
+		return nil
+	}
+
+	audience, err := claims.GetAudience()
+	if err != nil {
+		return ErrTokenInvalidAudience
+	}
+
+	for _, aud := range audience {
+		if slices.Contains(options.audience, aud) {
+			return nil
+		}
+	}
+
+	return ErrTokenInvalidAudience
+}
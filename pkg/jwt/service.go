@@ -1,108 +1,289 @@
 package jwt
 
 import (
-	"fmt"
 	"github.com/golang-jwt/jwt/v5"
-	"strconv"
 	"time"
 )
 
 type Service struct {
 	conf *Config
+	keys *KeyRing
 }
 
 func NewService(conf *Config) *Service {
-	return &Service{conf}
+	keys := conf.keyRing
+	if keys == nil {
+		keys = singleKeyRing(conf)
+	}
+
+	return &Service{
+		conf: conf,
+		keys: keys,
+	}
 }
 
-func (s *Service) IssueToken(sub string, customClaims map[string]string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, s.GetClaims(sub, customClaims))
+// KeyRing returns the keys used by the service, e.g. to rotate them at runtime.
+func (s *Service) KeyRing() *KeyRing {
+	return s.keys
+}
 
-	tokenString, err := token.SignedString([]byte(s.conf.secret))
-	if err != nil {
-		return "", mapError(err)
+// JWKS returns the public keys resource servers need to verify issued tokens.
+func (s *Service) JWKS() JSONWebKeySet {
+	return s.keys.JWKS()
+}
+
+// IssueOption changes the claims of a single issued token.
+type IssueOption func(*issueOptions)
+
+type issueOptions struct {
+	expiresIn time.Duration
+	notBefore time.Time
+	audience  []string
+	claims    map[string]interface{}
+	plain     bool
+}
+
+// WithExpiresIn overrides the configured token lifetime, e.g. for a client with its own TTL.
+func WithExpiresIn(expiresIn time.Duration) IssueOption {
+	return func(o *issueOptions) {
+		if expiresIn > 0 {
+			o.expiresIn = expiresIn
+		}
 	}
+}
 
-	return tokenString, nil
+// WithNotBefore sets the `nbf` claim, the token is rejected until then.
+func WithNotBefore(notBefore time.Time) IssueOption {
+	return func(o *issueOptions) {
+		o.notBefore = notBefore
+	}
 }
 
-func (s *Service) GetClaims(sub string, customClaims map[string]string) jwt.Claims {
-	now := time.Now().UTC()
+// WithAudience sets the `aud` claim instead of the configured audiences,
+// a single audience is written as a string.
+func WithAudience(audience ...string) IssueOption {
+	return func(o *issueOptions) {
+		o.audience = audience
+	}
+}
 
-	claims := jwt.MapClaims{
-		"sub": sub,
-		"iss": s.conf.issuer,
-		"exp": jwt.NewNumericDate(now.Add(s.conf.tokenExpiresIn)),
-		"iat": jwt.NewNumericDate(now),
+// WithoutEncryption issues a token that is only signed even when an encryption
+// key is configured, e.g. an ID token the client has to read.
+func WithoutEncryption() IssueOption {
+	return func(o *issueOptions) {
+		o.plain = true
 	}
+}
 
-	for k, v := range customClaims {
-		claims[k] = v
+// WithClaim adds a claim whose value is not a string, such as the numeric `auth_time` of an ID token.
+func WithClaim(name string, value interface{}) IssueOption {
+	return func(o *issueOptions) {
+		if o.claims == nil {
+			o.claims = make(map[string]interface{})
+		}
+
+		o.claims[name] = value
 	}
+}
 
-	return claims
+// IssueToken issues a token with string custom claims, it is a shortcut for
+// IssueClaims with one WithClaim option per custom claim.
+func (s *Service) IssueToken(sub string, customClaims map[string]string, opts ...IssueOption) (string, error) {
+	return s.IssueClaims(&RegisteredClaims{Subject: sub}, withCustomClaims(customClaims, opts)...)
 }
 
-func (s *Service) ParseTokenSubject(token string, withoutValidation bool) (string, error) {
-	originClaims, err := s.parseToken(token, withoutValidation)
+// IssueClaims signs the claims, a struct embedding RegisteredClaims, and
+// encrypts the token when an encryption key is configured.
+func (s *Service) IssueClaims(claims Claims, opts ...IssueOption) (string, error) {
+	options := s.issueOptions(opts)
+
+	key, err := s.keys.SigningKey()
 	if err != nil {
-		return "", mapError(err)
+		return "", err
+	}
+
+	method, err := key.Algorithm.method()
+	if err != nil {
+		return "", err
 	}
 
-	subject, err := originClaims.GetSubject()
+	signingKey, err := key.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, s.getClaims(claims, options))
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(signingKey)
 	if err != nil {
 		return "", mapError(err)
 	}
 
-	return subject, nil
+	if s.conf.encryptionKey == nil || options.plain {
+		return tokenString, nil
+	}
+
+	return s.conf.encryptionKey.encrypt(tokenString)
 }
 
-func (s *Service) ParseTokenClaims(token string) (map[string]string, error) {
-	originClaims, err := s.parseToken(token, false)
-	if err != nil {
-		return nil, mapError(err)
+func (s *Service) GetClaims(sub string, customClaims map[string]string) jwt.Claims {
+	return s.getClaims(&RegisteredClaims{Subject: sub}, s.issueOptions(withCustomClaims(customClaims, nil)))
+}
+
+func (s *Service) issueOptions(opts []IssueOption) issueOptions {
+	options := issueOptions{
+		expiresIn: s.conf.tokenExpiresIn,
+		audience:  s.conf.audience,
 	}
 
-	result := make(map[string]string)
+	if s.conf.issueNotBefore {
+		options.notBefore = time.Now().Add(s.conf.notBefore)
+	}
 
-	for key, value := range originClaims {
-		switch v := value.(type) {
-		case string:
-			result[key] = v
-		case float64:
-			result[key] = strconv.Itoa(int(v))
-		default:
-			result[key] = fmt.Sprint(v)
-		}
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	return result, nil
+	return options
 }
 
-func (s *Service) parseToken(token string, withoutValidation bool) (jwt.MapClaims, error) {
-	options := []jwt.ParserOption{
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Name}),
-		jwt.WithIssuer(s.conf.issuer),
+// getClaims fills the registered claims left empty by the caller and adds
+// the claims of WithClaim. The claims of the caller are not changed.
+func (s *Service) getClaims(claims Claims, options issueOptions) jwt.Claims {
+	now := time.Now().UTC()
+
+	issued := issuedClaims{
+		RegisteredClaims: *claims.registered(),
+		claims:           claims,
+		extra:            options.claims,
 	}
 
-	if withoutValidation {
-		options = append(options, jwt.WithoutClaimsValidation())
+	registered := &issued.RegisteredClaims
+	registered.Issuer = s.conf.issuer
+
+	if registered.IssuedAt == nil {
+		registered.IssuedAt = NewNumericDate(now)
 	}
 
-	parser := jwt.NewParser(options...)
+	if registered.ExpiresAt == nil {
+		registered.ExpiresAt = NewNumericDate(now.Add(options.expiresIn))
+	}
 
-	parsed, err := parser.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		_, err := t.Claims.GetSubject()
-		if err != nil {
-			return nil, mapError(err)
-		}
+	if registered.NotBefore == nil && !options.notBefore.IsZero() {
+		registered.NotBefore = NewNumericDate(options.notBefore)
+	}
+
+	if len(registered.Audience) == 0 {
+		registered.Audience = options.audience
+	}
 
-		return []byte(s.conf.secret), nil
-	})
+	return issued
+}
+
+// withCustomClaims puts the string claims of the map API before the options,
+// so claims set with WithClaim take precedence.
+func withCustomClaims(customClaims map[string]string, opts []IssueOption) []IssueOption {
+	custom := make([]IssueOption, 0, len(customClaims)+len(opts))
 
-	if claims, ok := parsed.Claims.(jwt.MapClaims); ok && parsed.Valid {
-		return claims, nil
+	for k, v := range customClaims {
+		custom = append(custom, WithClaim(k, v))
 	}
 
-	return nil, mapError(err)
+	return append(custom, opts...)
+}
+
+// Issuer returns the `iss` of issued tokens.
+func (s *Service) Issuer() string {
+	return s.conf.issuer
+}
+
+// Algorithms returns the algorithms of the keys tokens may be signed with.
+func (s *Service) Algorithms() []string {
+	return s.keys.algorithms()
+}
+
+func (s *Service) ParseTokenSubject(token string, withoutValidation bool, opts ...ParseOption) (string, error) {
+	return parseTokenSubject(s, token, s.conf.issuer, withoutValidation, s.parseOptions(opts))
+}
+
+func (s *Service) ParseTokenClaims(token string, opts ...ParseOption) (map[string]string, error) {
+	return parseTokenClaims(s, token, s.conf.issuer, false, s.parseOptions(opts))
+}
+
+// ParseClaims validates the token and decodes its claims into a struct embedding RegisteredClaims.
+func (s *Service) ParseClaims(token string, claims Claims, opts ...ParseOption) error {
+	return parseClaims(s, token, s.conf.issuer, false, claims, s.parseOptions(opts))
+}
+
+// ParseTokenClaimsWithoutValidation checks only the signature and issuer of
+// the token, so claims of an expired token can still be read.
+func (s *Service) ParseTokenClaimsWithoutValidation(token string) (map[string]string, error) {
+	return parseTokenClaims(s, token, s.conf.issuer, true, nil)
+}
+
+// parseOptions puts the configured checks before the options of the call.
+func (s *Service) parseOptions(opts []ParseOption) []ParseOption {
+	return append([]ParseOption{WithLeeway(s.conf.leeway), WithMaxAge(s.conf.maxAge)}, opts...)
+}
+
+func (s *Service) decryptionKey() *EncryptionKey {
+	return s.conf.encryptionKey
+}
+
+func (s *Service) verificationKey(t *jwt.Token) (interface{}, error) {
+	key, err := s.tokenKey(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.verificationKey()
+}
+
+func (s *Service) validMethods() []string {
+	return s.keys.algorithms()
+}
+
+// tokenKey picks the verification key by the `kid` header. Tokens issued
+// before key IDs were introduced have no header and are checked against the
+// current signing key.
+func (s *Service) tokenKey(t *jwt.Token) (*Key, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	var (
+		key *Key
+		err error
+	)
+
+	if kid == "" {
+		key, err = s.keys.SigningKey()
+	} else {
+		key, err = s.keys.VerificationKey(kid)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if string(key.Algorithm) != t.Method.Alg() {
+		return nil, ErrTokenSignatureInvalid
+	}
+
+	return key, nil
+}
+
+func singleKeyRing(conf *Config) *KeyRing {
+	key := &Key{
+		ID:         conf.keyID,
+		Algorithm:  conf.algorithm,
+		Secret:     []byte(conf.secret),
+		PrivateKey: conf.privateKey,
+		PublicKey:  conf.publicKey,
+		State:      KeyActive,
+	}
+
+	return &KeyRing{
+		keys: map[string]*Key{key.ID: key},
+		now:  time.Now,
+	}
 }
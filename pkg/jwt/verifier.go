@@ -0,0 +1,215 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const maxJWKSSize = 1 << 20
+
+// Verifier parses tokens issued by a remote Service using the public keys
+// it publishes as JWKS, so resource servers never need the signing secret.
+type Verifier struct {
+	conf  *VerifierConfig
+	group singleflight.Group
+
+	mu          sync.RWMutex
+	keys        map[string]*Key
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	now         func() time.Time
+}
+
+// NewVerifier creates a verifier and loads the key set once,
+// so misconfiguration is reported on startup.
+func NewVerifier(conf *VerifierConfig) (*Verifier, error) {
+	v := &Verifier{
+		conf: conf,
+		keys: make(map[string]*Key),
+		now:  time.Now,
+	}
+
+	if err := v.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (v *Verifier) ParseTokenSubject(token string, withoutValidation bool, opts ...ParseOption) (string, error) {
+	return parseTokenSubject(v, token, v.conf.issuer, withoutValidation, v.parseOptions(opts))
+}
+
+func (v *Verifier) ParseTokenClaims(token string, opts ...ParseOption) (map[string]string, error) {
+	return parseTokenClaims(v, token, v.conf.issuer, false, v.parseOptions(opts))
+}
+
+// ParseClaims validates the token and decodes its claims into a struct embedding RegisteredClaims.
+func (v *Verifier) ParseClaims(token string, claims Claims, opts ...ParseOption) error {
+	return parseClaims(v, token, v.conf.issuer, false, claims, v.parseOptions(opts))
+}
+
+// parseOptions puts the configured checks before the options of the call,
+// so a call can still require a different audience or max age.
+func (v *Verifier) parseOptions(opts []ParseOption) []ParseOption {
+	configured := []ParseOption{WithLeeway(v.conf.leeway), WithMaxAge(v.conf.maxAge)}
+
+	if len(v.conf.audience) > 0 {
+		configured = append(configured, WithExpectedAudience(v.conf.audience...))
+	}
+
+	return append(configured, opts...)
+}
+
+// Refresh reloads the key set unconditionally, concurrent calls share one request.
+func (v *Verifier) Refresh() error {
+	_, err, _ := v.group.Do("jwks", func() (interface{}, error) {
+		return nil, v.refresh()
+	})
+
+	return err
+}
+
+func (v *Verifier) decryptionKey() *EncryptionKey {
+	return v.conf.encryptionKey
+}
+
+func (v *Verifier) verificationKey(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, err := v.key(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(key.Algorithm) != t.Method.Alg() {
+		return nil, ErrTokenSignatureInvalid
+	}
+
+	return key.verificationKey()
+}
+
+// validMethods lists every asymmetric algorithm rather than those of cached keys,
+// because a key with a new algorithm is only fetched once its token is seen.
+// Symmetric algorithms are never accepted since JWKS does not carry secrets.
+func (v *Verifier) validMethods() []string {
+	return []string{string(RS256), string(ES256), string(EdDSA)}
+}
+
+// key returns the cached key, reloading the set when it is stale or the `kid`
+// is unknown. Reloads on unknown keys are rate limited by minRefreshInterval,
+// so tokens with made-up key IDs cannot flood the issuer with requests.
+// A stale set is reloaded in the background, only unknown keys wait for the
+// request, and the lock is never held while it is in flight.
+func (v *Verifier) key(kid string) (*Key, error) {
+	v.mu.RLock()
+	now := v.now()
+	stale := now.Sub(v.fetchedAt) >= v.conf.refreshInterval
+	allowed := now.Sub(v.attemptedAt) >= v.conf.minRefreshInterval
+	key, ok := v.lookup(kid)
+	v.mu.RUnlock()
+
+	switch {
+	case ok && stale && allowed:
+		go func() { _ = v.Refresh() }()
+	case !ok && allowed:
+		if err := v.Refresh(); err != nil {
+			return nil, err
+		}
+
+		v.mu.RLock()
+		key, ok = v.lookup(kid)
+		v.mu.RUnlock()
+	}
+
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+// lookup finds the key by ID, tokens without `kid` are accepted only
+// while the set holds a single key.
+func (v *Verifier) lookup(kid string) (*Key, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+
+	key, ok := v.keys[kid]
+
+	return key, ok
+}
+
+// refresh fetches the key set and swaps it in, it must only run through Refresh.
+func (v *Verifier) refresh() error {
+	v.mu.Lock()
+	v.attemptedAt = v.now()
+	attemptedAt := v.attemptedAt
+	v.mu.Unlock()
+
+	data, err := v.fetch()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrKeySetUnavailable, err)
+	}
+
+	var set JSONWebKeySet
+	if err = json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("%w: %w", ErrKeySetUnavailable, err)
+	}
+
+	keys := make(map[string]*Key, len(set.Keys))
+
+	for _, jwk := range set.Keys {
+		key, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+
+		keys[key.ID] = key
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = attemptedAt
+	v.mu.Unlock()
+
+	return nil
+}
+
+func (v *Verifier) fetch() ([]byte, error) {
+	if v.conf.jwksFile != "" {
+		return os.ReadFile(v.conf.jwksFile)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.conf.fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.conf.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.conf.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
@@ -0,0 +1,257 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func jwksServer(t *testing.T, svc *Service, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		err := json.NewEncoder(w).Encode(svc.JWKS())
+		assert.NoError(t, err)
+	}))
+}
+
+func TestVerifier_ParseTokenClaims(t *testing.T) {
+	key, _ := GenerateKey(RS256)
+
+	ring, _ := NewKeyRing(&Key{ID: "first", Algorithm: RS256, PrivateKey: key})
+	svc := NewService(NewConfig().
+		SetIssuer(issuer).
+		SetKeyRing(ring).
+		SetTokenExpiresIn(expiresIn))
+
+	var requests int32
+	server := jwksServer(t, svc, &requests)
+	defer server.Close()
+
+	verifier, err := NewVerifier(NewVerifierConfig().
+		SetJWKSURL(server.URL).
+		SetIssuer(issuer).
+		SetMinRefreshInterval(time.Hour))
+	if err != nil {
+		assert.Fail(t, "error on verifier creation")
+		return
+	}
+
+	token, _ := svc.IssueToken(subject, map[string]string{"key1": "val1"})
+
+	claims, err := verifier.ParseTokenClaims(token)
+	assert.NoError(t, err)
+	assert.Equal(t, subject, claims["sub"])
+	assert.Equal(t, "val1", claims["key1"])
+
+	sub, err := verifier.ParseTokenSubject(token, false)
+	assert.NoError(t, err)
+	assert.Equal(t, subject, sub)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	t.Run("Refresh on unknown kid", func(t *testing.T) {
+		newKey, _ := GenerateKey(EdDSA)
+		assert.NoError(t, ring.Add(&Key{ID: "second", Algorithm: EdDSA, PrivateKey: newKey, ActivatesAt: time.Now()}))
+		assert.NoError(t, ring.SetState("first", KeyVerifyOnly))
+
+		verifier.attemptedAt = time.Time{}
+
+		token, _ := svc.IssueToken(subject, nil)
+		assert.Equal(t, "second", tokenKID(t, token))
+
+		_, err := verifier.ParseTokenClaims(token)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Rate limited refresh", func(t *testing.T) {
+		otherKey, _ := GenerateKey(RS256)
+		other := NewService(NewConfig().
+			SetIssuer(issuer).
+			SetKeyID("unknown").
+			SetAlgorithm(RS256).
+			SetPrivateKey(otherKey).
+			SetTokenExpiresIn(expiresIn))
+
+		token, _ := other.IssueToken(subject, nil)
+
+		for i := 0; i < 3; i++ {
+			_, err := verifier.ParseTokenClaims(token)
+			assert.ErrorIs(t, err, ErrTokenUnverifiable)
+		}
+
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Symmetric token", func(t *testing.T) {
+		token, _ := NewService(testConf().SetKeyID("first")).IssueToken(subject, nil)
+
+		_, err := verifier.ParseTokenClaims(token)
+		assert.ErrorIs(t, err, ErrTokenSignatureInvalid)
+	})
+}
+
+func TestVerifier_SlowFetch(t *testing.T) {
+	key, _ := GenerateKey(ES256)
+	svc := NewService(NewConfig().
+		SetIssuer(issuer).
+		SetKeyID("first").
+		SetAlgorithm(ES256).
+		SetPrivateKey(key).
+		SetTokenExpiresIn(expiresIn))
+
+	var requests int32
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+
+			return
+		}
+
+		assert.NoError(t, json.NewEncoder(w).Encode(svc.JWKS()))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	verifier, err := NewVerifier(NewVerifierConfig().
+		SetJWKSURL(server.URL).
+		SetIssuer(issuer).
+		SetRefreshInterval(0).
+		SetMinRefreshInterval(0).
+		SetFetchTimeout(100 * time.Millisecond))
+	if err != nil {
+		assert.Fail(t, "error on verifier creation")
+		return
+	}
+
+	token, _ := svc.IssueToken(subject, nil)
+
+	started := time.Now()
+
+	for i := 0; i < 5; i++ {
+		_, err := verifier.ParseTokenClaims(token)
+		assert.NoError(t, err)
+	}
+
+	assert.Less(t, time.Since(started), 100*time.Millisecond, "cached keys must not wait for the reload")
+
+	unknown, _ := NewService(NewConfig().
+		SetIssuer(issuer).
+		SetKeyID("unknown").
+		SetAlgorithm(ES256).
+		SetPrivateKey(key).
+		SetTokenExpiresIn(expiresIn)).IssueToken(subject, nil)
+
+	started = time.Now()
+
+	_, err = verifier.ParseTokenClaims(unknown)
+	assert.ErrorIs(t, err, ErrTokenUnverifiable)
+	assert.Less(t, time.Since(started), time.Second, "the fetch has to time out")
+}
+
+func TestVerifier_JWKSFile(t *testing.T) {
+	key, _ := GenerateKey(ES256)
+	svc := NewService(NewConfig().
+		SetIssuer(issuer).
+		SetAlgorithm(ES256).
+		SetPrivateKey(key).
+		SetTokenExpiresIn(expiresIn))
+
+	data, _ := json.Marshal(svc.JWKS())
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	verifier, err := NewVerifier(NewVerifierConfig().
+		SetJWKSFile(path).
+		SetIssuer(issuer))
+	if err != nil {
+		assert.Fail(t, "error on verifier creation")
+		return
+	}
+
+	token, _ := svc.IssueToken(subject, nil)
+
+	sub, err := verifier.ParseTokenSubject(token, false)
+	assert.NoError(t, err)
+	assert.Equal(t, subject, sub)
+
+	_, err = NewVerifier(NewVerifierConfig().SetJWKSFile(filepath.Join(t.TempDir(), "missing.json")))
+	assert.ErrorIs(t, err, ErrKeySetUnavailable)
+}
+
+func TestVerifier_Audience(t *testing.T) {
+	key, _ := GenerateKey(ES256)
+	svc := NewService(NewConfig().
+		SetIssuer(issuer).
+		SetAlgorithm(ES256).
+		SetPrivateKey(key).
+		SetTokenExpiresIn(expiresIn))
+
+	var requests int32
+	server := jwksServer(t, svc, &requests)
+	defer server.Close()
+
+	verifier, err := NewVerifier(NewVerifierConfig().
+		SetJWKSURL(server.URL).
+		SetIssuer(issuer).
+		SetAudience("orders"))
+	if err != nil {
+		assert.Fail(t, "error on verifier creation")
+		return
+	}
+
+	token, _ := svc.IssueToken(subject, nil, WithAudience("orders"))
+
+	_, err = verifier.ParseTokenClaims(token)
+	assert.NoError(t, err)
+
+	token, _ = svc.IssueToken(subject, nil, WithAudience("billing"))
+
+	_, err = verifier.ParseTokenClaims(token)
+	assert.ErrorIs(t, err, ErrTokenInvalidAudience)
+
+	_, err = verifier.ParseTokenSubject(token, false)
+	assert.ErrorIs(t, err, ErrTokenInvalidAudience)
+}
+
+func TestVerifier_Encryption(t *testing.T) {
+	key, _ := GenerateKey(EdDSA)
+	encryptionKey := &EncryptionKey{Algorithm: Direct, Secret: []byte("0123456789abcdef0123456789abcdef")}
+
+	svc := NewService(NewConfig().
+		SetIssuer(issuer).
+		SetAlgorithm(EdDSA).
+		SetPrivateKey(key).
+		SetEncryptionKey(encryptionKey).
+		SetTokenExpiresIn(expiresIn))
+
+	var requests int32
+	server := jwksServer(t, svc, &requests)
+	defer server.Close()
+
+	verifier, err := NewVerifier(NewVerifierConfig().
+		SetJWKSURL(server.URL).
+		SetIssuer(issuer).
+		SetEncryptionKey(encryptionKey))
+	if err != nil {
+		assert.Fail(t, "error on verifier creation")
+		return
+	}
+
+	token, _ := svc.IssueToken(subject, map[string]string{"ip": "127.0.0.1"})
+
+	claims, err := verifier.ParseTokenClaims(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", claims["ip"])
+}
@@ -0,0 +1,50 @@
+package middleware
+
+// DefaultCookieName is the cookie the auth service puts the access token into.
+const DefaultCookieName = "token"
+
+type Config struct {
+	cookieName   string
+	realm        string
+	scopes       []string
+	audience     []string
+	allowClients bool
+}
+
+func NewConfig() *Config {
+	return &Config{
+		cookieName: DefaultCookieName,
+	}
+}
+
+// SetCookieName sets the cookie read when the request has no Authorization header,
+// an empty name accepts only the header.
+func (c *Config) SetCookieName(name string) *Config {
+	c.cookieName = name
+	return c
+}
+
+// SetRealm sets the realm reported in the WWW-Authenticate header.
+func (c *Config) SetRealm(realm string) *Config {
+	c.realm = realm
+	return c
+}
+
+// SetScopes requires the token to be granted all of the scopes.
+func (c *Config) SetScopes(scopes ...string) *Config {
+	c.scopes = scopes
+	return c
+}
+
+// SetAudience requires the `aud` of the token to contain one of the audiences,
+// usually the identifier of the service itself.
+func (c *Config) SetAudience(audience ...string) *Config {
+	c.audience = audience
+	return c
+}
+
+// SetAllowClients accepts tokens issued to a client itself, which carry no user.
+func (c *Config) SetAllowClients(allow bool) *Config {
+	c.allowClients = allow
+	return c
+}
@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Error codes of RFC 6750 section 3.1.
+const (
+	errInvalidRequest    = "invalid_request"
+	errInvalidToken      = "invalid_token"
+	errInsufficientScope = "insufficient_scope"
+)
+
+var errMalformedHeader = errors.New("malformed authorization header")
+
+// Token is a verified access token. User is nil for tokens issued to a client
+// itself, their Subject is the client ID.
+type Token struct {
+	Subject   string
+	TokenID   string
+	ClientID  string
+	Scopes    []string
+	Audience  []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	User      *User
+}
+
+// User is the user an access token was issued for, SessionID is its `sid`.
+// ClientID is set when the token was issued to an OAuth client on their behalf.
+type User struct {
+	ID        string
+	SessionID string
+	ClientID  string
+}
+
+// TokenParser verifies access tokens, see NewVerifierParser for one built on
+// the published keys of the auth service.
+type TokenParser interface {
+	ParseAccessToken(ctx context.Context, token string) (*Token, error)
+}
+
+// Authenticator lets through only requests with a valid access token.
+type Authenticator struct {
+	parser TokenParser
+	conf   *Config
+}
+
+func New(parser TokenParser, conf *Config) *Authenticator {
+	return &Authenticator{
+		parser: parser,
+		conf:   conf,
+	}
+}
+
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	tokenKey
+)
+
+// UserFromContext returns the user of the access token the request was authenticated with.
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userKey).(*User)
+
+	return user, ok && user != nil
+}
+
+// TokenFromContext returns the access token the request was authenticated with,
+// its User is nil for tokens issued to a client itself.
+func TokenFromContext(ctx context.Context) (*Token, bool) {
+	token, ok := ctx.Value(tokenKey).(*Token)
+
+	return token, ok
+}
+
+// Handler authenticates the request before passing it to next, the parsed
+// token and its user are put into the request context.
+func (a *Authenticator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := a.accessToken(r)
+		if err != nil {
+			a.writeError(w, errInvalidRequest, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		if raw == "" {
+			// no error code when the request carries no credentials at all
+			a.writeError(w, "", "", http.StatusUnauthorized)
+
+			return
+		}
+
+		token, err := a.parser.ParseAccessToken(r.Context(), raw)
+		if err != nil {
+			a.writeError(w, errInvalidToken, "invalid access token", http.StatusUnauthorized)
+
+			return
+		}
+
+		if token.User == nil && !a.conf.allowClients {
+			a.writeError(w, errInvalidToken, "token is not issued for a user", http.StatusUnauthorized)
+
+			return
+		}
+
+		if len(a.conf.audience) > 0 && !slices.ContainsFunc(token.Audience, func(aud string) bool {
+			return slices.Contains(a.conf.audience, aud)
+		}) {
+			a.writeError(w, errInvalidToken, "token is not issued for this audience", http.StatusUnauthorized)
+
+			return
+		}
+
+		for _, scope := range a.conf.scopes {
+			if !slices.Contains(token.Scopes, scope) {
+				a.writeError(w, errInsufficientScope, "token lacks required scope", http.StatusForbidden)
+
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), tokenKey, token)
+		ctx = context.WithValue(ctx, userKey, token.User)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// accessToken reads the bearer token from the Authorization header or, when
+// the header is absent, from the cookie. An empty token means no credentials.
+func (a *Authenticator) accessToken(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return "", errMalformedHeader
+		}
+
+		return strings.TrimSpace(token), nil
+	}
+
+	if a.conf.cookieName == "" {
+		return "", nil
+	}
+
+	cookie, err := r.Cookie(a.conf.cookieName)
+	if err != nil {
+		return "", nil
+	}
+
+	return cookie.Value, nil
+}
+
+type errorResp struct {
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// writeError responds with the WWW-Authenticate challenge of RFC 6750 section 3.
+func (a *Authenticator) writeError(w http.ResponseWriter, code, description string, status int) {
+	var params []string
+
+	if a.conf.realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", a.conf.realm))
+	}
+
+	if code != "" {
+		params = append(params, fmt.Sprintf("error=%q", code))
+	}
+
+	if description != "" {
+		params = append(params, fmt.Sprintf("error_description=%q", description))
+	}
+
+	if code == errInsufficientScope {
+		params = append(params, fmt.Sprintf("scope=%q", strings.Join(a.conf.scopes, " ")))
+	}
+
+	challenge := "Bearer"
+	if len(params) > 0 {
+		challenge += " " + strings.Join(params, ", ")
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(errorResp{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"auth/pkg/jwt"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const issuer = "test"
+
+// testParser returns the service issuing tokens like the auth service does and
+// a parser that, like a resource server, only has its published keys.
+func testParser(t *testing.T) (*jwt.Service, TokenParser) {
+	key, err := jwt.GenerateKey(jwt.ES256)
+	assert.NoError(t, err)
+
+	s := jwt.NewService(jwt.NewConfig().
+		SetAlgorithm(jwt.ES256).
+		SetPrivateKey(key).
+		SetIssuer(issuer).
+		SetTokenExpiresIn(5 * time.Minute))
+
+	data, err := json.Marshal(s.JWKS())
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	verifier, err := jwt.NewVerifier(jwt.NewVerifierConfig().SetJWKSFile(path).SetIssuer(issuer))
+	assert.NoError(t, err)
+
+	return s, NewVerifierParser(verifier)
+}
+
+// issueUser issues an access token of a user with the claims of the auth service.
+func issueUser(t *testing.T, s *jwt.Service, userID string) string {
+	accessToken, err := s.IssueToken(userID, map[string]string{
+		"ip":    "127.0.0.1",
+		"sid":   uuid.NewString(),
+		"jti":   uuid.NewString(),
+		"scope": "orders:read",
+	}, jwt.WithAudience("orders"))
+	assert.NoError(t, err)
+
+	return accessToken
+}
+
+// parserFunc turns a function into a TokenParser.
+type parserFunc func(ctx context.Context, token string) (*Token, error)
+
+func (f parserFunc) ParseAccessToken(ctx context.Context, token string) (*Token, error) {
+	return f(ctx, token)
+}
+
+// serve runs the request through the middleware, the handler behind it
+// responds with the ID of the user from the context.
+func serve(p TokenParser, conf *Config, r *http.Request) *httptest.ResponseRecorder {
+	handler := New(p, conf).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := UserFromContext(r.Context()); ok {
+			_, _ = w.Write([]byte(user.ID))
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestAuthenticator_Handler(t *testing.T) {
+	s, p := testParser(t)
+	userID := uuid.NewString()
+
+	accessToken := issueUser(t, s, userID)
+
+	t.Run("Header", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+accessToken)
+
+		w := serve(p, NewConfig(), r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, userID, w.Body.String())
+	})
+
+	t.Run("Cookie", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: accessToken})
+
+		w := serve(p, NewConfig(), r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, userID, w.Body.String())
+
+		w = serve(p, NewConfig().SetCookieName(""), r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("NoToken", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		w := serve(p, NewConfig().SetRealm("orders"), r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="orders"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("MalformedHeader", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+
+		w := serve(p, NewConfig(), r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_request"`)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+accessToken+"x")
+
+		w := serve(p, NewConfig(), r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+		var resp errorResp
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, errInvalidToken, resp.Error)
+	})
+
+	t.Run("Audience", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+accessToken)
+
+		w := serve(p, NewConfig().SetAudience("orders", "billing"), r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = serve(p, NewConfig().SetAudience("billing"), r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+	})
+
+	t.Run("Scopes", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+accessToken)
+
+		w := serve(p, NewConfig().SetScopes("orders:read"), r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = serve(p, NewConfig().SetScopes("orders:read", "orders:write"), r)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t,
+			`Bearer error="insufficient_scope", error_description="token lacks required scope", scope="orders:read orders:write"`,
+			w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		clientToken, err := s.IssueToken("billing", map[string]string{
+			"client_id": "billing",
+			"jti":       uuid.NewString(),
+			"scope":     "orders:read",
+		})
+		assert.NoError(t, err)
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+clientToken)
+
+		w := serve(p, NewConfig(), r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = serve(p, NewConfig().SetAllowClients(true), r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String(), "client tokens carry no user")
+	})
+
+	t.Run("Rejected by parser", func(t *testing.T) {
+		revoked := parserFunc(func(context.Context, string) (*Token, error) {
+			return nil, errors.New("token is revoked")
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+accessToken)
+
+		w := serve(revoked, NewConfig(), r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+	})
+}
+
+func TestVerifierParser_ParseAccessToken(t *testing.T) {
+	s, p := testParser(t)
+	userID := uuid.NewString()
+
+	token, err := p.ParseAccessToken(context.Background(), issueUser(t, s, userID))
+	assert.NoError(t, err)
+
+	assert.Equal(t, userID, token.Subject)
+	assert.Equal(t, userID, token.User.ID)
+	assert.NotEmpty(t, token.User.SessionID)
+	assert.NotEmpty(t, token.TokenID)
+	assert.Equal(t, []string{"orders:read"}, token.Scopes)
+	assert.Equal(t, []string{"orders"}, token.Audience)
+	assert.Equal(t, 5*time.Minute, token.ExpiresAt.Sub(token.IssuedAt))
+
+	// an ID token is signed with the same keys but is no access token
+	idToken, err := s.IssueToken(userID, map[string]string{"nonce": "n-0S6_WzA2Mj"}, jwt.WithAudience("app"))
+	assert.NoError(t, err)
+
+	_, err = p.ParseAccessToken(context.Background(), idToken)
+	assert.ErrorIs(t, err, ErrInvalidClaims)
+
+	other, _ := testParser(t)
+
+	_, err = p.ParseAccessToken(context.Background(), issueUser(t, other, userID))
+	assert.Error(t, err, "tokens signed with other keys are rejected")
+}
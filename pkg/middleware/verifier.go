@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"auth/pkg/jwt"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidClaims is returned for a verified token that is not an access token
+// of the auth service, e.g. an ID token.
+var ErrInvalidClaims = errors.New("invalid access token claims")
+
+// VerifierParser checks access tokens against the keys the auth service
+// publishes. Revoked tokens are accepted until they expire, services that
+// must reject them at once ask the introspection endpoint instead.
+type VerifierParser struct {
+	verifier *jwt.Verifier
+}
+
+func NewVerifierParser(verifier *jwt.Verifier) *VerifierParser {
+	return &VerifierParser{
+		verifier: verifier,
+	}
+}
+
+func (p *VerifierParser) ParseAccessToken(_ context.Context, token string) (*Token, error) {
+	claims, err := p.verifier.ParseTokenClaims(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return TokenFromClaims(claims)
+}
+
+// TokenFromClaims reads an access token from its claims flattened into strings,
+// as the parsers of pkg/jwt and pkg/paseto return them. Tokens without `sid`
+// are issued to a client and carry no user.
+func TokenFromClaims(claims map[string]string) (*Token, error) {
+	issuedAt, err := strconv.ParseInt(claims["iat"], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidClaims
+	}
+
+	expiresAt, err := strconv.ParseInt(claims["exp"], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidClaims
+	}
+
+	token := &Token{
+		Subject:   claims["sub"],
+		TokenID:   claims["jti"],
+		ClientID:  claims["client_id"],
+		Scopes:    strings.Fields(claims["scope"]),
+		IssuedAt:  time.Unix(issuedAt, 0),
+		ExpiresAt: time.Unix(expiresAt, 0),
+	}
+
+	if token.Subject == "" || token.TokenID == "" {
+		return nil, ErrInvalidClaims
+	}
+
+	if aud, ok := claims["aud"]; ok {
+		token.Audience = AudienceFromClaim(aud)
+	}
+
+	sessionID, ok := claims["sid"]
+	if !ok {
+		if token.ClientID == "" || token.Subject != token.ClientID {
+			return nil, ErrInvalidClaims
+		}
+
+		return token, nil
+	}
+
+	token.User = &User{
+		ID:        token.Subject,
+		SessionID: sessionID,
+		ClientID:  token.ClientID,
+	}
+
+	return token, nil
+}
+
+// AudienceFromClaim reads the `aud` claim, which is either a single audience or
+// a list of them kept as JSON by the parser.
+func AudienceFromClaim(aud string) []string {
+	var audience []string
+	if err := json.Unmarshal([]byte(aud), &audience); err == nil {
+		return audience
+	}
+
+	return []string{aud}
+}
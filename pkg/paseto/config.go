@@ -0,0 +1,83 @@
+package paseto
+
+import (
+	"crypto/ed25519"
+	"time"
+)
+
+type Config struct {
+	purpose        Purpose
+	localKey       []byte
+	privateKey     ed25519.PrivateKey
+	publicKey      ed25519.PublicKey
+	issuer         string
+	audience       []string
+	tokenExpiresIn time.Duration
+	leeway         time.Duration
+	maxAge         time.Duration
+}
+
+// NewConfig returns a config for v4.public tokens.
+func NewConfig() *Config {
+	return &Config{
+		purpose: Public,
+	}
+}
+
+// SetPurpose selects v4.local or v4.public tokens.
+func (c *Config) SetPurpose(purpose Purpose) *Config {
+	c.purpose = purpose
+	return c
+}
+
+// SetLocalKey sets the 32 byte key v4.local tokens are encrypted with.
+func (c *Config) SetLocalKey(key []byte) *Config {
+	c.localKey = key
+	return c
+}
+
+// SetPrivateKey sets the key v4.public tokens are signed with.
+// Its public part is used for verification unless SetPublicKey is called.
+func (c *Config) SetPrivateKey(key ed25519.PrivateKey) *Config {
+	c.privateKey = key
+	if len(key) == ed25519.PrivateKeySize && c.publicKey == nil {
+		c.publicKey = key.Public().(ed25519.PublicKey)
+	}
+	return c
+}
+
+// SetPublicKey sets the key v4.public tokens are verified with.
+// A config with only a public key can parse tokens but not issue them.
+func (c *Config) SetPublicKey(key ed25519.PublicKey) *Config {
+	c.publicKey = key
+	return c
+}
+
+func (c *Config) SetIssuer(issuer string) *Config {
+	c.issuer = issuer
+	return c
+}
+
+// SetAudience sets the default `aud` of issued tokens, WithAudience overrides it per token.
+func (c *Config) SetAudience(audience ...string) *Config {
+	c.audience = audience
+	return c
+}
+
+func (c *Config) SetTokenExpiresIn(expiresIn time.Duration) *Config {
+	c.tokenExpiresIn = expiresIn
+	return c
+}
+
+// SetLeeway tolerates clock skew between the issuer and the parser when
+// `exp`, `nbf` and `iat` are checked.
+func (c *Config) SetLeeway(leeway time.Duration) *Config {
+	c.leeway = leeway
+	return c
+}
+
+// SetMaxAge rejects parsed tokens issued longer ago than maxAge, whatever their `exp` is.
+func (c *Config) SetMaxAge(maxAge time.Duration) *Config {
+	c.maxAge = maxAge
+	return c
+}
@@ -0,0 +1,26 @@
+package paseto
+
+import "errors"
+
+var (
+	ErrInvalidKey                = errors.New("invalid key")
+	ErrUnsupportedToken          = errors.New("unsupported token version or purpose")
+	ErrTokenMalformed            = errors.New("token malformed")
+	ErrTokenSignatureInvalid     = errors.New("token signature invalid")
+	ErrTokenRequiredClaimMissing = errors.New("token required claim is missing")
+	ErrTokenExpired              = errors.New("token is expired")
+	ErrTokenUsedBeforeIssued     = errors.New("token used before issued")
+	ErrTokenInvalidIssuer        = errors.New("token has invalid issuer")
+	ErrTokenNotValidYet          = errors.New("token is not valid yet")
+	ErrTokenMaxAgeExceeded       = errors.New("token exceeds max age")
+	ErrInvalidType               = errors.New("invalid type for claim")
+)
+
+func ErrIsTiming(err error) bool {
+	if errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenNotValidYet) ||
+		errors.Is(err, ErrTokenUsedBeforeIssued) || errors.Is(err, ErrTokenMaxAgeExceeded) {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,34 @@
+package paseto
+
+import (
+	"crypto/ed25519"
+	"strings"
+)
+
+// paserkPublic is the PASERK header of v4.public keys.
+const paserkPublic = "k4.public."
+
+// KeySet lists the keys v4.public tokens are verified with as PASERK strings,
+// the PASETO counterpart of a JWKS.
+type KeySet struct {
+	Keys []string `json:"keys"`
+}
+
+// EncodePublicKey serializes the key as PASERK k4.public.
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return paserkPublic + encode(key)
+}
+
+// DecodePublicKey parses a PASERK k4.public key, e.g. one taken from a KeySet.
+func DecodePublicKey(paserk string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(paserk, paserkPublic) {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := decode(paserk[len(paserkPublic):])
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return key, nil
+}
@@ -0,0 +1,280 @@
+package paseto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	issuer    = "test-paseto"
+	expiresIn = time.Minute
+	subject   = "1"
+)
+
+func testConfigs(t *testing.T) map[Purpose]*Config {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	localKey := make([]byte, localKeySize)
+	_, err = rand.Read(localKey)
+	assert.NoError(t, err)
+
+	return map[Purpose]*Config{
+		Public: NewConfig().SetPrivateKey(privateKey).SetIssuer(issuer).SetTokenExpiresIn(expiresIn),
+		Local: NewConfig().SetPurpose(Local).SetLocalKey(localKey).SetIssuer(issuer).
+			SetTokenExpiresIn(expiresIn),
+	}
+}
+
+// vector is a test of testdata/v4.json, the v4 test vectors of the PASETO specification.
+type vector struct {
+	Name       string  `json:"name"`
+	ExpectFail bool    `json:"expect-fail"`
+	Key        string  `json:"key"`
+	Nonce      string  `json:"nonce"`
+	PublicKey  string  `json:"public-key"`
+	SecretKey  string  `json:"secret-key"`
+	Token      string  `json:"token"`
+	Payload    *string `json:"payload"`
+	Footer     string  `json:"footer"`
+	Implicit   string  `json:"implicit-assertion"`
+}
+
+func TestVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/v4.json")
+	assert.NoError(t, err)
+
+	var vectors struct {
+		Tests []vector `json:"tests"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &vectors))
+	assert.Len(t, vectors.Tests, 17)
+
+	for _, v := range vectors.Tests {
+		t.Run(v.Name, func(t *testing.T) {
+			implicit := []byte(v.Implicit)
+
+			var (
+				message, footer []byte
+				err             error
+			)
+
+			if v.Key != "" {
+				message, footer, err = decrypt(mustHex(t, v.Key), v.Token, implicit)
+			} else {
+				message, footer, err = verify(mustHex(t, v.PublicKey), v.Token, implicit)
+			}
+
+			if v.ExpectFail {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, *v.Payload, string(message))
+			assert.Equal(t, v.Footer, string(footer))
+
+			var token string
+
+			if v.Key != "" {
+				token, err = seal(mustHex(t, v.Key), mustHex(t, v.Nonce), []byte(*v.Payload), []byte(v.Footer), implicit)
+			} else {
+				token, err = sign(mustHex(t, v.SecretKey), []byte(*v.Payload), []byte(v.Footer), implicit)
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, v.Token, token)
+
+			if v.Implicit != "" {
+				if v.Key != "" {
+					_, _, err = decrypt(mustHex(t, v.Key), v.Token, nil)
+				} else {
+					_, _, err = verify(mustHex(t, v.PublicKey), v.Token, nil)
+				}
+
+				assert.ErrorIs(t, err, ErrTokenSignatureInvalid, "the implicit assertion is authenticated")
+			}
+		})
+	}
+}
+
+func TestPublicKey_PASERK(t *testing.T) {
+	key := ed25519.PublicKey(mustHex(t, "1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"))
+
+	// test vector k4.public-1 of the PASERK specification
+	paserk := EncodePublicKey(key)
+	assert.Equal(t, "k4.public.Hrnbu7wEfAP9cGBOAHHwmH4Wsot1ciXBHwBBXQ4gsaI", paserk)
+
+	decoded, err := DecodePublicKey(paserk)
+	assert.NoError(t, err)
+	assert.Equal(t, key, decoded)
+
+	_, err = DecodePublicKey("k4.local.Hrnbu7wEfAP9cGBOAHHwmH4Wsot1ciXBHwBBXQ4gsaI")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = DecodePublicKey("k4.public.Hrnbu7wEfAP9")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestService_KeySet(t *testing.T) {
+	configs := testConfigs(t)
+
+	set := NewService(configs[Public]).KeySet()
+	assert.Len(t, set.Keys, 1)
+
+	key, err := DecodePublicKey(set.Keys[0])
+	assert.NoError(t, err)
+
+	// a consumer with only the published key verifies the tokens
+	token, err := NewService(configs[Public]).IssueToken(subject, nil)
+	assert.NoError(t, err)
+
+	_, err = NewService(NewConfig().SetPublicKey(key).SetIssuer(issuer)).ParseTokenClaims(token)
+	assert.NoError(t, err)
+
+	assert.Empty(t, NewService(configs[Local]).KeySet().Keys, "v4.local keys are secret")
+}
+
+func TestService_IssueToken(t *testing.T) {
+	for purpose, conf := range testConfigs(t) {
+		t.Run(string(purpose), func(t *testing.T) {
+			svc := NewService(conf)
+
+			token, err := svc.IssueToken(subject, map[string]string{"ip": "127.0.0.1"},
+				WithAudience("orders"), WithClaim("act", map[string]string{"sub": "gateway"}))
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(token, "v4."+string(purpose)+"."))
+
+			claims, err := svc.ParseTokenClaims(token)
+			assert.NoError(t, err)
+
+			assert.Equal(t, subject, claims["sub"])
+			assert.Equal(t, issuer, claims["iss"])
+			assert.Equal(t, "127.0.0.1", claims["ip"])
+			assert.Equal(t, "orders", claims["aud"])
+			assert.JSONEq(t, `{"sub":"gateway"}`, claims["act"])
+
+			iat, _ := time.Parse(time.RFC3339, time.Now().UTC().Format(time.RFC3339))
+			assert.InDelta(t, iat.Unix(), mustAtoi(t, claims["iat"]), 1)
+			assert.Equal(t, mustAtoi(t, claims["iat"])+int64(expiresIn.Seconds()), mustAtoi(t, claims["exp"]))
+		})
+	}
+}
+
+func TestService_Audience(t *testing.T) {
+	for purpose, conf := range testConfigs(t) {
+		t.Run(string(purpose), func(t *testing.T) {
+			svc := NewService(conf.SetAudience("orders", "billing"))
+
+			token, err := svc.IssueToken(subject, nil)
+			assert.NoError(t, err)
+
+			claims, err := svc.ParseTokenClaims(token)
+			assert.NoError(t, err)
+			assert.Equal(t, `["orders","billing"]`, claims["aud"])
+
+			token, err = svc.IssueToken(subject, nil, WithAudience("payments"))
+			assert.NoError(t, err)
+
+			claims, err = svc.ParseTokenClaims(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "payments", claims["aud"])
+		})
+	}
+}
+
+func TestService_ParseTokenClaims(t *testing.T) {
+	for purpose, conf := range testConfigs(t) {
+		t.Run(string(purpose), func(t *testing.T) {
+			svc := NewService(conf)
+
+			token, err := svc.IssueToken(subject, nil)
+			assert.NoError(t, err)
+
+			t.Run("Tampered token", func(t *testing.T) {
+				tampered := []byte(token)
+				tampered[len(tampered)-5] ^= 1
+
+				_, err := svc.ParseTokenClaims(string(tampered))
+				assert.Error(t, err)
+			})
+
+			t.Run("Other purpose", func(t *testing.T) {
+				other := Public
+				if purpose == Public {
+					other = Local
+				}
+
+				_, err := NewService(testConfigs(t)[other]).ParseTokenClaims(token)
+				assert.ErrorIs(t, err, ErrUnsupportedToken)
+			})
+
+			t.Run("Other key", func(t *testing.T) {
+				_, err := NewService(testConfigs(t)[purpose]).ParseTokenClaims(token)
+				assert.ErrorIs(t, err, ErrTokenSignatureInvalid)
+			})
+
+			t.Run("Expired token", func(t *testing.T) {
+				svc := NewService(conf)
+				svc.now = func() time.Time { return time.Now().Add(2 * expiresIn) }
+
+				_, err := svc.ParseTokenClaims(token)
+				assert.ErrorIs(t, err, ErrTokenExpired)
+				assert.True(t, ErrIsTiming(err))
+
+				claims, err := svc.ParseTokenClaimsWithoutValidation(token)
+				assert.NoError(t, err)
+				assert.Equal(t, subject, claims["sub"])
+			})
+
+			t.Run("Leeway and max age", func(t *testing.T) {
+				skewed := *conf
+				svc := NewService(skewed.SetLeeway(5 * time.Second).SetMaxAge(time.Second))
+				svc.now = func() time.Time { return time.Now().Add(-3 * time.Second) }
+
+				_, err := svc.ParseTokenClaims(token)
+				assert.NoError(t, err, "token from a clock ahead of ours")
+
+				svc.now = func() time.Time { return time.Now().Add(10 * time.Second) }
+
+				_, err = svc.ParseTokenClaims(token)
+				assert.ErrorIs(t, err, ErrTokenMaxAgeExceeded)
+			})
+
+			t.Run("Invalid issuer", func(t *testing.T) {
+				other := *conf
+
+				_, err := NewService(other.SetIssuer("other")).ParseTokenClaims(token)
+				assert.ErrorIs(t, err, ErrTokenInvalidIssuer)
+
+				_, err = NewService(other.SetIssuer("other")).ParseTokenClaimsWithoutValidation(token)
+				assert.ErrorIs(t, err, ErrTokenInvalidIssuer)
+			})
+		})
+	}
+}
+
+func mustAtoi(t *testing.T, s string) int64 {
+	t.Helper()
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	assert.NoError(t, err)
+
+	return n
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+	assert.NoError(t, err)
+
+	return data
+}
@@ -0,0 +1,263 @@
+package paseto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+	"strings"
+)
+
+// Purpose selects between v4.local, encrypted with a shared key, and
+// v4.public, signed with Ed25519 and readable by anyone.
+type Purpose string
+
+const (
+	Local  Purpose = "local"
+	Public Purpose = "public"
+)
+
+const (
+	version = "v4"
+
+	localKeySize = 32
+	nonceSize    = 32
+	macSize      = 32
+
+	encryptionKeyInfo = "paseto-encryption-key"
+	authKeyInfo       = "paseto-auth-key-for-aead"
+)
+
+func (p Purpose) header() string {
+	return version + "." + string(p) + "."
+}
+
+// sign creates a v4.public token. The footer is authenticated but sent in the
+// clear, the implicit assertion is authenticated without being sent at all.
+func sign(key ed25519.PrivateKey, message, footer, implicit []byte) (string, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return "", ErrInvalidKey
+	}
+
+	h := Public.header()
+	sig := ed25519.Sign(key, pae([]byte(h), message, footer, implicit))
+
+	return h + encode(append(message, sig...)) + encodeFooter(footer), nil
+}
+
+// verify checks a v4.public token and returns its message and footer.
+func verify(key ed25519.PublicKey, token string, implicit []byte) ([]byte, []byte, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, nil, ErrInvalidKey
+	}
+
+	h := Public.header()
+
+	payload, footer, err := split(token, h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(payload) < ed25519.SignatureSize {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	message := payload[:len(payload)-ed25519.SignatureSize]
+	sig := payload[len(payload)-ed25519.SignatureSize:]
+
+	if !ed25519.Verify(key, pae([]byte(h), message, footer, implicit), sig) {
+		return nil, nil, ErrTokenSignatureInvalid
+	}
+
+	return message, footer, nil
+}
+
+// encrypt creates a v4.local token with XChaCha20 and a keyed BLAKE2b MAC under a random nonce.
+func encrypt(key, message, footer, implicit []byte) (string, error) {
+	n := make([]byte, nonceSize)
+	if _, err := rand.Read(n); err != nil {
+		return "", err
+	}
+
+	return seal(key, n, message, footer, implicit)
+}
+
+// seal encrypts with the given nonce, which must never repeat under the same key.
+func seal(key, n, message, footer, implicit []byte) (string, error) {
+	if len(key) != localKeySize || len(n) != nonceSize {
+		return "", ErrInvalidKey
+	}
+
+	h := Local.header()
+
+	encryptionKey, n2, authKey, err := splitKey(key, n)
+	if err != nil {
+		return "", err
+	}
+
+	c := make([]byte, len(message))
+	if err = xor(encryptionKey, n2, c, message); err != nil {
+		return "", err
+	}
+
+	t, err := mac(authKey, pae([]byte(h), n, c, footer, implicit))
+	if err != nil {
+		return "", err
+	}
+
+	payload := make([]byte, 0, len(n)+len(c)+len(t))
+	payload = append(append(append(payload, n...), c...), t...)
+
+	return h + encode(payload) + encodeFooter(footer), nil
+}
+
+// decrypt checks the MAC of a v4.local token before decrypting its message,
+// it returns the message and the footer.
+func decrypt(key []byte, token string, implicit []byte) ([]byte, []byte, error) {
+	if len(key) != localKeySize {
+		return nil, nil, ErrInvalidKey
+	}
+
+	h := Local.header()
+
+	payload, footer, err := split(token, h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(payload) < nonceSize+macSize {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	n := payload[:nonceSize]
+	c := payload[nonceSize : len(payload)-macSize]
+	t := payload[len(payload)-macSize:]
+
+	encryptionKey, n2, authKey, err := splitKey(key, n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expected, err := mac(authKey, pae([]byte(h), n, c, footer, implicit))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if subtle.ConstantTimeCompare(t, expected) != 1 {
+		return nil, nil, ErrTokenSignatureInvalid
+	}
+
+	message := make([]byte, len(c))
+	if err = xor(encryptionKey, n2, message, c); err != nil {
+		return nil, nil, err
+	}
+
+	return message, footer, nil
+}
+
+// splitKey derives the encryption key, the XChaCha20 nonce and the authentication key from the nonce of the token.
+func splitKey(key, n []byte) ([]byte, []byte, []byte, error) {
+	tmp, err := keyedHash(key, 56, []byte(encryptionKeyInfo), n)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	authKey, err := keyedHash(key, 32, []byte(authKeyInfo), n)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return tmp[:32], tmp[32:], authKey, nil
+}
+
+func mac(authKey, preAuth []byte) ([]byte, error) {
+	return keyedHash(authKey, macSize, preAuth)
+}
+
+func keyedHash(key []byte, size int, parts ...[]byte) ([]byte, error) {
+	h, err := blake2b.New(size, key)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, part := range parts {
+		h.Write(part)
+	}
+
+	return h.Sum(nil), nil
+}
+
+func xor(key, nonce, dst, src []byte) error {
+	stream, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return err
+	}
+
+	stream.XORKeyStream(dst, src)
+
+	return nil
+}
+
+// pae is the pre-authentication encoding, it makes the concatenation of the
+// pieces unambiguous before they are signed or authenticated.
+func pae(pieces ...[]byte) []byte {
+	out := make([]byte, 8, 8+len(pieces)*8)
+	binary.LittleEndian.PutUint64(out, uint64(len(pieces))&^(1<<63))
+
+	for _, piece := range pieces {
+		var length [8]byte
+		binary.LittleEndian.PutUint64(length[:], uint64(len(piece))&^(1<<63))
+
+		out = append(append(out, length[:]...), piece...)
+	}
+
+	return out
+}
+
+// split checks the header of the token and decodes its payload and footer.
+func split(token, header string) ([]byte, []byte, error) {
+	if !strings.HasPrefix(token, header) {
+		return nil, nil, ErrUnsupportedToken
+	}
+
+	parts := strings.Split(token[len(header):], ".")
+	if len(parts) > 2 {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	payload, err := decode(parts[0])
+	if err != nil {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	var footer []byte
+
+	if len(parts) == 2 {
+		if footer, err = decode(parts[1]); err != nil {
+			return nil, nil, ErrTokenMalformed
+		}
+	}
+
+	return payload, footer, nil
+}
+
+// decode rejects non-canonical encodings, whose unused trailing bits would
+// let several strings stand for the same token.
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.Strict().DecodeString(s)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeFooter(footer []byte) string {
+	if len(footer) == 0 {
+		return ""
+	}
+
+	return "." + encode(footer)
+}
@@ -0,0 +1,259 @@
+package paseto
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Service issues and parses PASETO v4 tokens. The version and purpose are
+// fixed by the config and never read from the token, so there is no
+// algorithm to confuse.
+type Service struct {
+	conf *Config
+	now  func() time.Time
+}
+
+func NewService(conf *Config) *Service {
+	return &Service{
+		conf: conf,
+		now:  time.Now,
+	}
+}
+
+// IssueOption changes the claims of a single issued token.
+type IssueOption func(*issueOptions)
+
+type issueOptions struct {
+	expiresIn time.Duration
+	audience  []string
+	claims    map[string]interface{}
+}
+
+// WithExpiresIn overrides the configured token lifetime.
+func WithExpiresIn(expiresIn time.Duration) IssueOption {
+	return func(o *issueOptions) {
+		if expiresIn > 0 {
+			o.expiresIn = expiresIn
+		}
+	}
+}
+
+// WithAudience sets the `aud` claim instead of the configured audiences,
+// a single audience is written as a string.
+func WithAudience(audience ...string) IssueOption {
+	return func(o *issueOptions) {
+		o.audience = audience
+	}
+}
+
+// WithClaim adds a claim whose value is not a string.
+func WithClaim(name string, value interface{}) IssueOption {
+	return func(o *issueOptions) {
+		if o.claims == nil {
+			o.claims = make(map[string]interface{})
+		}
+
+		o.claims[name] = value
+	}
+}
+
+// IssueToken issues a token with the registered claims `iss`, `sub`, `iat`,
+// `nbf` and `exp`, times are written as RFC 3339 strings as PASETO requires.
+func (s *Service) IssueToken(sub string, customClaims map[string]string, opts ...IssueOption) (string, error) {
+	options := issueOptions{
+		expiresIn: s.conf.tokenExpiresIn,
+		audience:  s.conf.audience,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	now := s.now().UTC()
+
+	claims := map[string]interface{}{
+		"iss": s.conf.issuer,
+		"sub": sub,
+		"iat": now.Format(time.RFC3339),
+		"nbf": now.Format(time.RFC3339),
+		"exp": now.Add(options.expiresIn).Format(time.RFC3339),
+	}
+
+	for k, v := range customClaims {
+		claims[k] = v
+	}
+
+	for k, v := range options.claims {
+		claims[k] = v
+	}
+
+	switch len(options.audience) {
+	case 0:
+	case 1:
+		claims["aud"] = options.audience[0]
+	default:
+		claims["aud"] = options.audience
+	}
+
+	message, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	if s.conf.purpose == Local {
+		return encrypt(s.conf.localKey, message, nil, nil)
+	}
+
+	return sign(s.conf.privateKey, message, nil, nil)
+}
+
+// KeySet returns the public key of v4.public tokens, it is empty for v4.local
+// whose key must stay secret.
+func (s *Service) KeySet() KeySet {
+	set := KeySet{Keys: []string{}}
+
+	if s.conf.purpose == Public && len(s.conf.publicKey) == ed25519.PublicKeySize {
+		set.Keys = append(set.Keys, EncodePublicKey(s.conf.publicKey))
+	}
+
+	return set
+}
+
+// ParseTokenClaims validates the token and flattens its claims into strings
+// like the JWT service does: times become Unix seconds and nested claims JSON.
+func (s *Service) ParseTokenClaims(token string) (map[string]string, error) {
+	return s.parseTokenClaims(token, false)
+}
+
+// ParseTokenClaimsWithoutValidation checks only the signature or MAC and the
+// issuer of the token, so claims of an expired token can still be read.
+func (s *Service) ParseTokenClaimsWithoutValidation(token string) (map[string]string, error) {
+	return s.parseTokenClaims(token, true)
+}
+
+func (s *Service) parseTokenClaims(token string, withoutValidation bool) (map[string]string, error) {
+	var (
+		message []byte
+		err     error
+	)
+
+	if s.conf.purpose == Local {
+		message, _, err = decrypt(s.conf.localKey, token, nil)
+	} else {
+		message, _, err = verify(s.conf.publicKey, token, nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err = json.Unmarshal(message, &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	times := make(map[string]time.Time)
+
+	for _, name := range []string{"exp", "nbf", "iat"} {
+		value, ok := claims[name]
+		if !ok {
+			continue
+		}
+
+		str, ok := value.(string)
+		if !ok {
+			return nil, ErrInvalidType
+		}
+
+		t, err := time.Parse(time.RFC3339, str)
+		if err != nil {
+			return nil, ErrInvalidType
+		}
+
+		times[name] = t
+	}
+
+	// the issuer is checked even without validation, only the times are skipped
+	if issuer, _ := claims["iss"].(string); issuer != s.conf.issuer {
+		return nil, ErrTokenInvalidIssuer
+	}
+
+	if !withoutValidation {
+		if err = s.validate(times); err != nil {
+			return nil, err
+		}
+	}
+
+	return flatten(claims, times)
+}
+
+// validate checks the times of the token, `exp` is required.
+func (s *Service) validate(times map[string]time.Time) error {
+	now := s.now()
+	leeway := s.conf.leeway
+
+	exp, ok := times["exp"]
+	if !ok {
+		return ErrTokenRequiredClaimMissing
+	}
+
+	if now.After(exp.Add(leeway)) {
+		return ErrTokenExpired
+	}
+
+	if nbf, ok := times["nbf"]; ok && now.Add(leeway).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+
+	iat, ok := times["iat"]
+	if ok && now.Add(leeway).Before(iat) {
+		return ErrTokenUsedBeforeIssued
+	}
+
+	if s.conf.maxAge <= 0 {
+		return nil
+	}
+
+	if !ok {
+		return ErrTokenRequiredClaimMissing
+	}
+
+	if now.Sub(iat) > s.conf.maxAge+leeway {
+		return ErrTokenMaxAgeExceeded
+	}
+
+	return nil
+}
+
+func flatten(claims map[string]interface{}, times map[string]time.Time) (map[string]string, error) {
+	result := make(map[string]string, len(claims))
+
+	for key, value := range claims {
+		if t, ok := times[key]; ok {
+			result[key] = strconv.FormatInt(t.Unix(), 10)
+
+			continue
+		}
+
+		switch v := value.(type) {
+		case string:
+			result[key] = v
+		case float64:
+			result[key] = strconv.Itoa(int(v))
+		case map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, ErrInvalidType
+			}
+
+			result[key] = string(encoded)
+		default:
+			result[key] = fmt.Sprint(v)
+		}
+	}
+
+	return result, nil
+}